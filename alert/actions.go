@@ -0,0 +1,182 @@
+package alert
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"time"
+)
+
+const (
+	defaultExecTimeout    = 30 * time.Second
+	defaultWebhookTimeout = 10 * time.Second
+)
+
+// Action is something done when a rule fires.
+//
+//	{"type": "exec", "command": ["/usr/local/bin/notify", "--urgent"]}
+//	{"type": "webhook", "url": "https://example.com/hook", "headers": {"Authorization": "Bearer x"}}
+//	{"type": "log"}
+//	{"type": "log", "path": "/var/log/pnmap-alerts.log"}
+//
+// Commands get the alert as JSON on stdin and in PNMAP_* environment
+// variables. Webhooks get it as the body of a POST. The log action logs to
+// the engine logger, or appends a JSON line to path if set.
+type Action struct {
+	Type string `json:"type"`
+
+	// Command is the program and its arguments for exec actions. It is
+	// not run by a shell.
+	Command []string `json:"command,omitempty"`
+
+	// URL is the webhook to POST to.
+	URL string `json:"url,omitempty"`
+
+	// Headers are added to webhook requests.
+	Headers map[string]string `json:"headers,omitempty"`
+
+	// Path is the file log actions append to.
+	Path string `json:"path,omitempty"`
+
+	// Timeout bounds exec and webhook actions.
+	Timeout Duration `json:"timeout,omitempty"`
+}
+
+func (a *Action) validate() error {
+	switch a.Type {
+	case "exec":
+		if len(a.Command) == 0 {
+			return fmt.Errorf("exec action without command")
+		}
+
+	case "webhook":
+		if a.URL == "" {
+			return fmt.Errorf("webhook action without url")
+		}
+
+	case "log":
+
+	default:
+		return fmt.Errorf("unknown action type '%s'", a.Type)
+	}
+
+	return nil
+}
+
+func (a *Action) timeout(def time.Duration) time.Duration {
+	if a.Timeout > 0 {
+		return time.Duration(a.Timeout)
+	}
+
+	return def
+}
+
+func (a *Action) run(logger *slog.Logger, alert *Alert) error {
+	switch a.Type {
+	case "exec":
+		return a.exec(alert)
+	case "webhook":
+		return a.webhook(alert)
+	case "log":
+		if a.Path == "" {
+			logger.Warn("alert",
+				"rule", alert.Rule,
+				"event", alert.Event,
+				"mac", alert.MAC,
+				"value", alert.Value,
+				"source", alert.Source,
+				"suppressed", alert.Suppressed,
+			)
+
+			return nil
+		}
+
+		return a.appendLog(alert)
+	}
+
+	return nil
+}
+
+func (a *Action) exec(alert *Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout(defaultExecTimeout))
+	defer cancel()
+
+	cmd := exec.CommandContext(ctx, a.Command[0], a.Command[1:]...)
+	cmd.Stdin = bytes.NewReader(body)
+	cmd.Env = append(os.Environ(),
+		"PNMAP_RULE="+alert.Rule,
+		"PNMAP_EVENT="+alert.Event.String(),
+		"PNMAP_MAC="+alert.MAC,
+		"PNMAP_VALUE="+alert.Value,
+		"PNMAP_SOURCE="+alert.Source,
+		fmt.Sprintf("PNMAP_SUPPRESSED=%d", alert.Suppressed),
+	)
+
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("%s: %w: %s", a.Command[0], err, bytes.TrimSpace(out))
+	}
+
+	return nil
+}
+
+func (a *Action) webhook(alert *Alert) error {
+	body, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), a.timeout(defaultWebhookTimeout))
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range a.Headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("%s: %s", a.URL, resp.Status)
+	}
+
+	return nil
+}
+
+func (a *Action) appendLog(alert *Alert) error {
+	line, err := json.Marshal(alert)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(a.Path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return err
+	}
+
+	_, err = f.Write(append(line, '\n'))
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
@@ -0,0 +1,303 @@
+package alert
+
+import (
+	"log/slog"
+	"sync"
+	"time"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+// Alert is passed to actions when a rule fires.
+type Alert struct {
+	Rule   string
+	Event  intel.EventType
+	MAC    string
+	Value  string
+	Source string
+	Time   time.Time
+	NIC    *intel.NIC
+
+	// Suppressed is the number of alerts from the rule suppressed by
+	// dedupe or rate limiting since it last fired.
+	Suppressed int
+}
+
+type job struct {
+	alert   *Alert
+	actions []Action
+}
+
+// ruleState is the dedupe and rate limiting state of a rule.
+type ruleState struct {
+	// last is when an alert for a key last fired.
+	last      map[string]time.Time
+	lastPrune time.Time
+
+	// fired is when alerts fired within the rate limit window.
+	fired []time.Time
+
+	suppressed int
+
+	// stale is the last seen time of stations reported stale by rules
+	// with StaleAfter, so they are reported once until seen again.
+	stale map[string]time.Time
+}
+
+// staleCheckInterval is how often stations are checked for rules with
+// StaleAfter.
+const staleCheckInterval = time.Minute
+
+// Engine matches events against rules and runs actions. Actions run one
+// at a time in the background, so a slow webhook delays other actions but
+// never the Intel.
+type Engine struct {
+	mu     sync.Mutex
+	rules  *Rules
+	state  map[string]*ruleState
+	logger *slog.Logger
+
+	// nics are the latest copies of stations, for rules with StaleAfter.
+	nics           map[string]*intel.NIC
+	lastStaleCheck time.Time
+
+	jobs chan job
+}
+
+// New returns an Engine for rules. Errors and log actions go to logger.
+func New(rules *Rules, logger *slog.Logger) *Engine {
+	return &Engine{
+		rules:  rules,
+		state:  make(map[string]*ruleState),
+		logger: logger,
+		nics:   make(map[string]*intel.NIC),
+		jobs:   make(chan job, 100),
+	}
+}
+
+// Track adds stations known before events are handled, so rules with
+// StaleAfter can fire for stations never seen again.
+func (e *Engine) Track(nics intel.NICCollection) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	for mac, nic := range nics {
+		if _, found := e.nics[mac]; !found {
+			e.nics[mac] = nic
+		}
+	}
+}
+
+// SetRules replaces the rules. Dedupe and rate limiting state is kept for
+// rules with the same name.
+func (e *Engine) SetRules(rules *Rules) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.rules = rules
+}
+
+// Run handles events from sub until it is closed. Stations are checked for
+// rules with StaleAfter every minute, by event time and by the clock.
+func (e *Engine) Run(sub *intel.Subscription) {
+	done := make(chan struct{})
+	go e.work(done)
+
+	ticker := time.NewTicker(staleCheckInterval)
+	defer ticker.Stop()
+
+	var dropped uint64
+
+	for {
+		select {
+		case event, ok := <-sub.C:
+			if !ok {
+				close(e.jobs)
+				<-done
+
+				return
+			}
+
+			if d := sub.Dropped(); d != dropped {
+				e.logger.Warn("alert engine too slow, events dropped", "dropped", d-dropped)
+				dropped = d
+			}
+
+			e.handle(event)
+
+		case now := <-ticker.C:
+			e.CheckStale(now)
+		}
+	}
+}
+
+// CheckStale fires rules with StaleAfter for stations not seen for that
+// long before now.
+func (e *Engine) CheckStale(now time.Time) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	e.checkStale(now)
+}
+
+func (e *Engine) checkStale(now time.Time) {
+	e.lastStaleCheck = now
+
+	for _, rule := range e.rules.Rules {
+		if rule.StaleAfter <= 0 {
+			continue
+		}
+
+		state := e.ruleState(rule)
+
+		for mac, nic := range e.nics {
+			if now.Sub(nic.LastSeen) <= time.Duration(rule.StaleAfter) {
+				continue
+			}
+
+			if reported, found := state.stale[mac]; found && reported.Equal(nic.LastSeen) {
+				continue
+			}
+
+			state.stale[mac] = nic.LastSeen
+
+			e.fire(rule, state, intel.Event{
+				Type: intel.NICStale,
+				MAC:  mac,
+				Time: now,
+				NIC:  nic,
+			})
+		}
+	}
+}
+
+func (e *Engine) work(done chan struct{}) {
+	defer close(done)
+
+	for j := range e.jobs {
+		for _, a := range j.actions {
+			err := a.run(e.logger, j.alert)
+			if err != nil {
+				e.logger.Error("alert action failed", "rule", j.alert.Rule, "action", a.Type, "error", err)
+			}
+		}
+	}
+}
+
+func (e *Engine) handle(event intel.Event) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+
+	if event.NIC != nil {
+		e.nics[event.MAC] = event.NIC
+	}
+
+	for _, rule := range e.rules.Rules {
+		// These rules decide staleness themselves.
+		if rule.StaleAfter > 0 {
+			continue
+		}
+
+		e.fire(rule, e.ruleState(rule), event)
+	}
+
+	if event.Time.Sub(e.lastStaleCheck) >= staleCheckInterval {
+		e.checkStale(event.Time)
+	}
+}
+
+// ruleState returns the state of rule, creating it if needed.
+func (e *Engine) ruleState(rule *Rule) *ruleState {
+	state, found := e.state[rule.Name]
+	if !found {
+		state = &ruleState{
+			last:  make(map[string]time.Time),
+			stale: make(map[string]time.Time),
+		}
+		e.state[rule.Name] = state
+	}
+
+	return state
+}
+
+// fire queues the actions of rule for event, unless deduped or rate
+// limited. It must be called with the lock held.
+func (e *Engine) fire(rule *Rule, state *ruleState, event intel.Event) {
+	if !rule.Match.match(event) {
+		return
+	}
+
+	if !state.allow(rule, event) {
+		state.suppressed++
+
+		return
+	}
+
+	alert := &Alert{
+		Rule:       rule.Name,
+		Event:      event.Type,
+		MAC:        event.MAC,
+		Value:      event.Value,
+		Source:     event.Source,
+		Time:       event.Time,
+		NIC:        event.NIC,
+		Suppressed: state.suppressed,
+	}
+	state.suppressed = 0
+
+	select {
+	case e.jobs <- job{alert, rule.Actions}:
+	default:
+		e.logger.Warn("alert queue full, alert dropped", "rule", rule.Name, "mac", event.MAC)
+	}
+}
+
+// allow returns true if an alert for event should fire, and records it if
+// so. Event time is used, so captures replay with the same result.
+func (s *ruleState) allow(rule *Rule, event intel.Event) bool {
+	now := event.Time
+	window := time.Duration(*rule.Dedupe)
+
+	key := event.Type.String() + "\x00" + event.MAC + "\x00" + event.Value
+
+	if window > 0 {
+		if now.Sub(s.lastPrune) > window {
+			for k, t := range s.last {
+				if now.Sub(t) >= window {
+					delete(s.last, k)
+				}
+			}
+
+			s.lastPrune = now
+		}
+
+		if last, found := s.last[key]; found && now.Sub(last) < window {
+			return false
+		}
+	}
+
+	if rule.Limit > 0 {
+		per := time.Duration(rule.Per)
+
+		n := 0
+		for _, t := range s.fired {
+			if now.Sub(t) < per {
+				s.fired[n] = t
+				n++
+			}
+		}
+		s.fired = s.fired[:n]
+
+		if len(s.fired) >= rule.Limit {
+			return false
+		}
+
+		s.fired = append(s.fired, now)
+	}
+
+	if window > 0 {
+		s.last[key] = now
+	}
+
+	return true
+}
@@ -0,0 +1,502 @@
+package alert
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+// syncBuffer is a bytes.Buffer safe for concurrent writes.
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.buf.String()
+}
+
+func compiled(t *testing.T, rules ...*Rule) *Rules {
+	t.Helper()
+
+	r := &Rules{Rules: rules}
+
+	err := r.compile()
+	if err != nil {
+		t.Fatalf("compile: %s", err)
+	}
+
+	return r
+}
+
+func TestWebhook(t *testing.T) {
+	type request struct {
+		method      string
+		contentType string
+		token       string
+		alert       Alert
+	}
+
+	requests := make(chan request, 10)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		req := request{
+			method:      r.Method,
+			contentType: r.Header.Get("Content-Type"),
+			token:       r.Header.Get("X-Token"),
+		}
+
+		err := json.Unmarshal(body, &req.alert)
+		if err != nil {
+			t.Errorf("decoding webhook body: %s", err)
+		}
+
+		requests <- req
+	}))
+	defer server.Close()
+
+	rules := compiled(t, &Rule{
+		Name:  "hostname",
+		Match: Match{Events: []intel.EventType{intel.HostnameAdded}},
+		Actions: []Action{{
+			Type:    "webhook",
+			URL:     server.URL,
+			Headers: map[string]string{"X-Token": "secret"},
+		}},
+	})
+
+	i := intel.New()
+	sub := i.Subscribe(100)
+
+	e := New(rules, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	done := make(chan struct{})
+	go func() {
+		e.Run(sub)
+		close(done)
+	}()
+
+	i.Merge(
+		intel.Fact{Type: intel.IPAdded, MAC: "02:00:00:00:00:01", Source: "arp", Value: "192.0.2.1"},
+		intel.Fact{Type: intel.HostnameAdded, MAC: "02:00:00:00:00:01", Source: "mdns", Value: "printer"},
+	)
+
+	select {
+	case req := <-requests:
+		if req.method != http.MethodPost {
+			t.Errorf("method is %s, expected POST", req.method)
+		}
+
+		if req.contentType != "application/json" {
+			t.Errorf("content type is '%s'", req.contentType)
+		}
+
+		if req.token != "secret" {
+			t.Errorf("header X-Token is '%s', expected 'secret'", req.token)
+		}
+
+		if req.alert.Rule != "hostname" || req.alert.Event != intel.HostnameAdded || req.alert.MAC != "02:00:00:00:00:01" || req.alert.Value != "printer" || req.alert.Source != "mdns" {
+			t.Errorf("unexpected alert %+v", req.alert)
+		}
+
+		if req.alert.NIC == nil || !req.alert.NIC.IPs.Contains("192.0.2.1") {
+			t.Errorf("alert lacks the station: %+v", req.alert.NIC)
+		}
+
+	case <-time.After(5 * time.Second):
+		t.Fatal("webhook not called")
+	}
+
+	sub.Close()
+	<-done
+
+	if len(requests) > 0 {
+		t.Errorf("%d unexpected webhook requests", len(requests))
+	}
+}
+
+func TestWebhookError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	rules := compiled(t, &Rule{
+		Name:    "all",
+		Actions: []Action{{Type: "webhook", URL: server.URL}},
+	})
+
+	i := intel.New()
+	sub := i.Subscribe(100)
+
+	var logs syncBuffer
+	e := New(rules, slog.New(slog.NewTextHandler(&logs, nil)))
+
+	done := make(chan struct{})
+	go func() {
+		e.Run(sub)
+		close(done)
+	}()
+
+	i.Merge(intel.Fact{Type: intel.NICDiscovered, MAC: "02:00:00:00:00:01"})
+
+	// Closing the subscription waits for queued actions.
+	sub.Close()
+	<-done
+
+	if !strings.Contains(logs.String(), "alert action failed") || !strings.Contains(logs.String(), "502") {
+		t.Errorf("failed webhook not logged: %s", logs.String())
+	}
+}
+
+func TestStaleAfter(t *testing.T) {
+	dedupe := Duration(0)
+
+	rules := compiled(t, &Rule{
+		Name:       "gone",
+		Dedupe:     &dedupe,
+		StaleAfter: Duration(time.Hour),
+		Actions:    []Action{{Type: "log"}},
+	})
+
+	if rules.NeedsStaleEvents() {
+		t.Error("rule with stale_after should not need NICStale events from the Intel")
+	}
+
+	e := New(rules, slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	e.Track(intel.NICCollection{
+		"02:00:00:00:00:01": {MAC: "02:00:00:00:00:01", LastSeen: t0},
+		"02:00:00:00:00:02": {MAC: "02:00:00:00:00:02", LastSeen: t0.Add(30 * time.Minute)},
+	})
+
+	fired := func() []string {
+		var macs []string
+
+		for {
+			select {
+			case j := <-e.jobs:
+				if j.alert.Event != intel.NICStale {
+					t.Errorf("unexpected event %s", j.alert.Event)
+				}
+
+				macs = append(macs, j.alert.MAC)
+
+			default:
+				return macs
+			}
+		}
+	}
+
+	e.CheckStale(t0.Add(50 * time.Minute))
+	if macs := fired(); len(macs) != 0 {
+		t.Errorf("fired too early for %v", macs)
+	}
+
+	e.CheckStale(t0.Add(70 * time.Minute))
+	if macs := fired(); len(macs) != 1 || macs[0] != "02:00:00:00:00:01" {
+		t.Errorf("expected 02:00:00:00:00:01 to go stale, got %v", macs)
+	}
+
+	e.CheckStale(t0.Add(80 * time.Minute))
+	if macs := fired(); len(macs) != 0 {
+		t.Errorf("fired again for %v", macs)
+	}
+
+	// Seen again, and gone again.
+	e.handle(intel.Event{
+		Type: intel.NICSeen,
+		MAC:  "02:00:00:00:00:01",
+		Time: t0.Add(95 * time.Minute),
+		NIC:  &intel.NIC{MAC: "02:00:00:00:00:01", LastSeen: t0.Add(95 * time.Minute)},
+	})
+
+	if macs := fired(); len(macs) != 1 || macs[0] != "02:00:00:00:00:02" {
+		t.Errorf("expected 02:00:00:00:00:02 to go stale by event time, got %v", macs)
+	}
+
+	e.CheckStale(t0.Add(160 * time.Minute))
+	if macs := fired(); len(macs) != 1 || macs[0] != "02:00:00:00:00:01" {
+		t.Errorf("expected 02:00:00:00:00:01 to go stale again, got %v", macs)
+	}
+}
+
+func TestStaleAfterOnlyMatchesStale(t *testing.T) {
+	r := &Rules{Rules: []*Rule{{
+		Name:       "bad",
+		Match:      Match{Events: []intel.EventType{intel.IPAdded}},
+		StaleAfter: Duration(time.Hour),
+		Actions:    []Action{{Type: "log"}},
+	}}}
+
+	if r.compile() == nil {
+		t.Error("stale_after with IPAdded should not compile")
+	}
+}
+
+// drain returns the alerts queued by e.
+func drain(e *Engine) []*Alert {
+	var alerts []*Alert
+
+	for {
+		select {
+		case j := <-e.jobs:
+			alerts = append(alerts, j.alert)
+
+		default:
+			return alerts
+		}
+	}
+}
+
+func TestDedupeAndLimit(t *testing.T) {
+	duration := func(d time.Duration) *Duration {
+		dd := Duration(d)
+
+		return &dd
+	}
+
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	hostname := func(offset time.Duration, mac string, value string) intel.Event {
+		return intel.Event{Type: intel.HostnameAdded, MAC: mac, Value: value, Time: t0.Add(offset)}
+	}
+
+	cases := []struct {
+		name   string
+		rule   Rule
+		events []intel.Event
+
+		// fired is the number of alerts expected, and suppressed the
+		// Suppressed count of the last one.
+		fired      int
+		suppressed int
+	}{
+		{
+			name: "default dedupe",
+			rule: Rule{},
+			events: []intel.Event{
+				hostname(0, "02:00:00:00:00:01", "a"),
+				hostname(time.Minute, "02:00:00:00:00:01", "a"),
+				hostname(59*time.Minute, "02:00:00:00:00:01", "a"),
+				hostname(2*time.Minute, "02:00:00:00:00:01", "b"),
+				hostname(3*time.Minute, "02:00:00:00:00:02", "a"),
+			},
+			fired: 3,
+		},
+		{
+			name: "dedupe window passed",
+			rule: Rule{Dedupe: duration(10 * time.Minute)},
+			events: []intel.Event{
+				hostname(0, "02:00:00:00:00:01", "a"),
+				hostname(5*time.Minute, "02:00:00:00:00:01", "a"),
+				hostname(10*time.Minute, "02:00:00:00:00:01", "a"),
+				hostname(15*time.Minute, "02:00:00:00:00:01", "a"),
+			},
+			fired:      2,
+			suppressed: 1,
+		},
+		{
+			name: "dedupe disabled",
+			rule: Rule{Dedupe: duration(0)},
+			events: []intel.Event{
+				hostname(0, "02:00:00:00:00:01", "a"),
+				hostname(0, "02:00:00:00:00:01", "a"),
+				hostname(0, "02:00:00:00:00:01", "a"),
+			},
+			fired: 3,
+		},
+		{
+			name: "limit",
+			rule: Rule{Dedupe: duration(0), Limit: 2, Per: Duration(time.Hour)},
+			events: []intel.Event{
+				hostname(0, "02:00:00:00:00:01", "a"),
+				hostname(time.Minute, "02:00:00:00:00:02", "a"),
+				hostname(2*time.Minute, "02:00:00:00:00:03", "a"),
+				hostname(3*time.Minute, "02:00:00:00:00:04", "a"),
+				hostname(61*time.Minute, "02:00:00:00:00:05", "a"),
+			},
+			fired:      3,
+			suppressed: 2,
+		},
+		{
+			// Deduped alerts do not count against the limit.
+			name: "dedupe and limit",
+			rule: Rule{Limit: 2, Per: Duration(time.Hour)},
+			events: []intel.Event{
+				hostname(0, "02:00:00:00:00:01", "a"),
+				hostname(time.Minute, "02:00:00:00:00:01", "a"),
+				hostname(2*time.Minute, "02:00:00:00:00:01", "a"),
+				hostname(3*time.Minute, "02:00:00:00:00:02", "a"),
+				hostname(4*time.Minute, "02:00:00:00:00:03", "a"),
+			},
+			fired:      2,
+			suppressed: 2,
+		},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			rule := c.rule
+			rule.Name = "test"
+			rule.Actions = []Action{{Type: "log"}}
+
+			e := New(compiled(t, &rule), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+			for _, event := range c.events {
+				e.handle(event)
+			}
+
+			alerts := drain(e)
+			if len(alerts) != c.fired {
+				t.Fatalf("%d alerts fired, expected %d", len(alerts), c.fired)
+			}
+
+			if last := alerts[len(alerts)-1]; last.Suppressed != c.suppressed {
+				t.Errorf("last alert suppressed %d, expected %d", last.Suppressed, c.suppressed)
+			}
+		})
+	}
+}
+
+// TestSetRules checks that dedupe state follows rules by name when they are
+// reordered.
+func TestSetRules(t *testing.T) {
+	rule := func(name string, events ...intel.EventType) *Rule {
+		return &Rule{
+			Name:    name,
+			Match:   Match{Events: events},
+			Actions: []Action{{Type: "log"}},
+		}
+	}
+
+	e := New(compiled(t, rule("ip", intel.IPAdded), rule("hostname", intel.HostnameAdded)), slog.New(slog.NewTextHandler(io.Discard, nil)))
+
+	t0 := time.Date(2026, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	e.handle(intel.Event{Type: intel.IPAdded, MAC: "02:00:00:00:00:01", Value: "192.0.2.1", Time: t0})
+	e.handle(intel.Event{Type: intel.HostnameAdded, MAC: "02:00:00:00:00:01", Value: "printer", Time: t0})
+
+	if alerts := drain(e); len(alerts) != 2 {
+		t.Fatalf("%d alerts fired, expected 2", len(alerts))
+	}
+
+	e.SetRules(compiled(t, rule("hostname", intel.HostnameAdded), rule("ip", intel.IPAdded)))
+
+	e.handle(intel.Event{Type: intel.IPAdded, MAC: "02:00:00:00:00:01", Value: "192.0.2.1", Time: t0.Add(time.Minute)})
+	e.handle(intel.Event{Type: intel.HostnameAdded, MAC: "02:00:00:00:00:01", Value: "printer", Time: t0.Add(time.Minute)})
+
+	if alerts := drain(e); len(alerts) != 0 {
+		t.Errorf("reordered rules fired again: %+v", alerts)
+	}
+}
+
+func TestRuleNames(t *testing.T) {
+	cases := map[string][]*Rule{
+		"unnamed":   {{Actions: []Action{{Type: "log"}}}},
+		"duplicate": {{Name: "a", Actions: []Action{{Type: "log"}}}, {Name: "a", Actions: []Action{{Type: "log"}}}},
+	}
+
+	for name, rules := range cases {
+		r := &Rules{Rules: rules}
+
+		if r.compile() == nil {
+			t.Errorf("%s rules should not compile", name)
+		}
+	}
+}
+
+func TestExec(t *testing.T) {
+	dir := t.TempDir()
+
+	rules := compiled(t,
+		&Rule{
+			Name:  "hostname",
+			Match: Match{Events: []intel.EventType{intel.HostnameAdded}},
+			Actions: []Action{{
+				Type:    "exec",
+				Command: []string{"sh", "-c", `cat > "$0/stdin" && echo "$PNMAP_RULE $PNMAP_EVENT $PNMAP_MAC $PNMAP_VALUE $PNMAP_SOURCE $PNMAP_SUPPRESSED" > "$0/env"`, dir},
+			}},
+		},
+		&Rule{
+			Name:    "failing",
+			Match:   Match{Events: []intel.EventType{intel.IPAdded}},
+			Actions: []Action{{Type: "exec", Command: []string{"sh", "-c", "echo broken; exit 3"}}},
+		},
+	)
+
+	i := intel.New()
+	sub := i.Subscribe(100)
+
+	var logs syncBuffer
+	e := New(rules, slog.New(slog.NewTextHandler(&logs, nil)))
+
+	done := make(chan struct{})
+	go func() {
+		e.Run(sub)
+		close(done)
+	}()
+
+	i.Merge(
+		intel.Fact{Type: intel.IPAdded, MAC: "02:00:00:00:00:01", Source: "arp", Value: "192.0.2.1"},
+		intel.Fact{Type: intel.HostnameAdded, MAC: "02:00:00:00:00:01", Source: "mdns", Value: "printer"},
+	)
+
+	// Closing the subscription waits for queued actions.
+	sub.Close()
+	<-done
+
+	env, err := os.ReadFile(filepath.Join(dir, "env"))
+	if err != nil {
+		t.Fatalf("command not run: %s", err)
+	}
+
+	if string(env) != "hostname HostnameAdded 02:00:00:00:00:01 printer mdns 0\n" {
+		t.Errorf("command got environment '%s'", env)
+	}
+
+	stdin, err := os.ReadFile(filepath.Join(dir, "stdin"))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	var alert Alert
+
+	err = json.Unmarshal(stdin, &alert)
+	if err != nil {
+		t.Fatalf("decoding stdin: %s", err)
+	}
+
+	if alert.Rule != "hostname" || alert.NIC == nil || !alert.NIC.IPs.Contains("192.0.2.1") {
+		t.Errorf("command got alert %+v", alert)
+	}
+
+	if !strings.Contains(logs.String(), "alert action failed") || !strings.Contains(logs.String(), "exit status 3: broken") {
+		t.Errorf("failed command not logged: %s", logs.String())
+	}
+}
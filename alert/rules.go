@@ -0,0 +1,348 @@
+// Package alert fires actions when events from an Intel match rules.
+package alert
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/abrander/pnmap/intel"
+	"github.com/abrander/pnmap/oui"
+)
+
+// DefaultDedupe is the dedupe window used by rules not setting one.
+const DefaultDedupe = time.Hour
+
+// Duration is a time.Duration read from JSON as a string like "1h30m".
+type Duration time.Duration
+
+// UnmarshalJSON implements json.Unmarshaler.
+func (d *Duration) UnmarshalJSON(data []byte) error {
+	var s string
+
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+
+	parsed, err := time.ParseDuration(s)
+	if err != nil {
+		return err
+	}
+
+	*d = Duration(parsed)
+
+	return nil
+}
+
+// MarshalJSON implements json.Marshaler.
+func (d Duration) MarshalJSON() ([]byte, error) {
+	return json.Marshal(time.Duration(d).String())
+}
+
+// Rules is a rules file.
+type Rules struct {
+	Rules []*Rule `json:"rules"`
+}
+
+// Rule fires its actions for events matching Match.
+type Rule struct {
+	// Name identifies the rule, and must be unique.
+	Name  string `json:"name"`
+	Match Match  `json:"match"`
+
+	// Dedupe suppresses alerts for the same station and value within this
+	// window. Unset means DefaultDedupe, "0s" disables deduping.
+	Dedupe *Duration `json:"dedupe,omitempty"`
+
+	// Limit is the maximum number of alerts fired per Per. Zero means no
+	// limit.
+	Limit int      `json:"limit,omitempty"`
+	Per   Duration `json:"per,omitempty"`
+
+	// StaleAfter makes the rule fire once for stations not seen for this
+	// long, until seen again, instead of matching NICStale events from
+	// the Intel. The rule matches no other events.
+	StaleAfter Duration `json:"stale_after,omitempty"`
+
+	Actions []Action `json:"actions"`
+}
+
+// Match selects events. All fields set must match. Globs use path.Match
+// syntax and are case-insensitive.
+type Match struct {
+	// Events are the event types to match. Empty matches all events but
+	// NICSeen.
+	Events []intel.EventType `json:"events,omitempty"`
+
+	// MAC is a glob matched against the address of the station.
+	MAC string `json:"mac,omitempty"`
+
+	// Value is a glob matched against the value of *Added events.
+	Value string `json:"value,omitempty"`
+
+	// Source is the name of the dissector causing the event.
+	Source string `json:"source,omitempty"`
+
+	// Vendor is a substring of the OUI vendor or an observed vendor.
+	Vendor string `json:"vendor,omitempty"`
+
+	// IP is a network in CIDR notation containing one of the addresses of
+	// the station.
+	IP string `json:"ip,omitempty"`
+
+	// Hostname is a glob matched against the hostnames of the station.
+	Hostname string `json:"hostname,omitempty"`
+
+	// Application must be one of the applications of the station.
+	Application string `json:"application,omitempty"`
+
+	// Changed matches *Added events for stations that already had another
+	// value of the same kind, like a hostname change.
+	Changed bool `json:"changed,omitempty"`
+
+	network *net.IPNet
+}
+
+// Load reads rules from path.
+func Load(path string) (*Rules, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	rules := &Rules{}
+
+	err = json.Unmarshal(data, rules)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	err = rules.compile()
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return rules, nil
+}
+
+// compile validates the rules and fills in defaults.
+func (r *Rules) compile() error {
+	names := make(map[string]bool, len(r.Rules))
+
+	for n, rule := range r.Rules {
+		// Dedupe and rate limiting state is kept by name when the rules
+		// are reloaded, so names must identify rules.
+		if rule.Name == "" {
+			return fmt.Errorf("rule %d: no name", n+1)
+		}
+
+		if names[rule.Name] {
+			return fmt.Errorf("rule '%s': name used more than once", rule.Name)
+		}
+
+		names[rule.Name] = true
+
+		if rule.Dedupe == nil {
+			d := Duration(DefaultDedupe)
+			rule.Dedupe = &d
+		}
+
+		if rule.Limit > 0 && rule.Per <= 0 {
+			return fmt.Errorf("rule '%s': limit requires per", rule.Name)
+		}
+
+		if rule.StaleAfter > 0 {
+			for _, t := range rule.Match.Events {
+				if t != intel.NICStale {
+					return fmt.Errorf("rule '%s': stale_after only matches NICStale events", rule.Name)
+				}
+			}
+
+			rule.Match.Events = []intel.EventType{intel.NICStale}
+		}
+
+		for _, glob := range []string{rule.Match.MAC, rule.Match.Value, rule.Match.Hostname} {
+			_, err := path.Match(glob, "")
+			if err != nil {
+				return fmt.Errorf("rule '%s': bad pattern '%s': %w", rule.Name, glob, err)
+			}
+		}
+
+		if rule.Match.IP != "" {
+			_, network, err := net.ParseCIDR(rule.Match.IP)
+			if err != nil {
+				return fmt.Errorf("rule '%s': %w", rule.Name, err)
+			}
+
+			rule.Match.network = network
+		}
+
+		if len(rule.Actions) == 0 {
+			return fmt.Errorf("rule '%s': no actions", rule.Name)
+		}
+
+		for _, a := range rule.Actions {
+			err := a.validate()
+			if err != nil {
+				return fmt.Errorf("rule '%s': %w", rule.Name, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// Uses returns true if any rule matches events of type t explicitly.
+func (r *Rules) Uses(t intel.EventType) bool {
+	for _, rule := range r.Rules {
+		for _, e := range rule.Match.Events {
+			if e == t {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// NeedsStaleEvents returns true if any rule matches NICStale events from
+// the Intel, which are only emitted with intel.WithStaleAfter. Rules with
+// StaleAfter decide staleness themselves.
+func (r *Rules) NeedsStaleEvents() bool {
+	for _, rule := range r.Rules {
+		if rule.StaleAfter == 0 && slices.Contains(rule.Match.Events, intel.NICStale) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func glob(pattern string, value string) bool {
+	matched, _ := path.Match(strings.ToLower(pattern), strings.ToLower(value))
+
+	return matched
+}
+
+func anyGlob(pattern string, values []string) bool {
+	for _, v := range values {
+		if glob(pattern, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// observations returns the observations of nic changed by events of type t.
+func observations(nic *intel.NIC, t intel.EventType) intel.Observations {
+	switch t {
+	case intel.IPAdded:
+		return nic.IPs
+	case intel.HostnameAdded:
+		return nic.Hostnames
+	case intel.UserAgentAdded:
+		return nic.UserAgents
+	case intel.VendorAdded:
+		return nic.Vendor
+	case intel.ApplicationAdded:
+		return nic.Applications
+	}
+
+	return nil
+}
+
+func (m *Match) match(e intel.Event) bool {
+	if len(m.Events) == 0 {
+		if e.Type == intel.NICSeen {
+			return false
+		}
+	} else {
+		found := false
+		for _, t := range m.Events {
+			if t == e.Type {
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	if m.MAC != "" && !glob(m.MAC, e.MAC) {
+		return false
+	}
+
+	if m.Value != "" && !glob(m.Value, e.Value) {
+		return false
+	}
+
+	if m.Source != "" && m.Source != e.Source {
+		return false
+	}
+
+	nic := e.NIC
+	if nic == nil {
+		nic = &intel.NIC{MAC: e.MAC}
+	}
+
+	if m.Vendor != "" && !m.matchVendor(nic) {
+		return false
+	}
+
+	if m.network != nil && !m.matchIP(nic) {
+		return false
+	}
+
+	if m.Hostname != "" && !anyGlob(m.Hostname, nic.Hostnames.Values()) {
+		return false
+	}
+
+	if m.Application != "" && !nic.Applications.Contains(m.Application) {
+		return false
+	}
+
+	if m.Changed {
+		values := observations(nic, e.Type).Values()
+		if len(values) < 2 {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (m *Match) matchVendor(nic *intel.NIC) bool {
+	needle := strings.ToLower(m.Vendor)
+
+	if strings.Contains(strings.ToLower(oui.Vendor(nic.MAC)), needle) {
+		return true
+	}
+
+	for _, v := range nic.Vendor.Values() {
+		if strings.Contains(strings.ToLower(v), needle) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Match) matchIP(nic *intel.NIC) bool {
+	for _, v := range nic.IPs.Values() {
+		ip := net.ParseIP(v)
+		if ip != nil && m.network.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
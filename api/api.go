@@ -0,0 +1,196 @@
+// Package api serves the stations of an Intel as JSON over HTTP.
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/abrander/pnmap/intel"
+	"github.com/abrander/pnmap/oui"
+)
+
+const (
+	defaultLimit = 100
+	maxLimit     = 1000
+)
+
+// NIC is a station as returned by the API.
+type NIC struct {
+	*intel.NIC
+
+	OUIVendor string `json:"OUIVendor"`
+}
+
+// Page is a page of stations.
+type Page struct {
+	Total  int   `json:"Total"`
+	Offset int   `json:"Offset"`
+	Limit  int   `json:"Limit"`
+	NICs   []NIC `json:"NICs"`
+}
+
+type api struct {
+	intel *intel.Intel
+}
+
+// New returns a handler serving the stations of i:
+//
+//	GET /api/nics        stations matching the query, see below.
+//	GET /api/nics/{mac}  a single station.
+//
+// Stations can be filtered with the query parameters mac, ip, hostname and
+// application, which must match exactly, and vendor, which matches a
+// substring of the OUI vendor or any observed vendor. Results are sorted by
+// MAC address and paginated with offset and limit.
+func New(i *intel.Intel) http.Handler {
+	a := &api{intel: i}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/nics", a.list)
+	mux.HandleFunc("GET /api/nics/{mac}", a.get)
+
+	return mux
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+
+	_ = json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, message string) {
+	writeJSON(w, status, map[string]string{"Error": message})
+}
+
+// containsFold returns true if any of values equals needle ignoring case.
+func containsFold(values []string, needle string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, needle) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// filter describes the query parameters of a lookup.
+type filter struct {
+	mac         string
+	ip          string
+	hostname    string
+	application string
+	vendor      string
+}
+
+func (f *filter) match(nic *intel.NIC) bool {
+	if f.mac != "" && !strings.EqualFold(nic.MAC, f.mac) {
+		return false
+	}
+
+	if f.ip != "" && !nic.IPs.Contains(f.ip) {
+		return false
+	}
+
+	if f.hostname != "" && !containsFold(nic.Hostnames.Values(), f.hostname) {
+		return false
+	}
+
+	if f.application != "" && !containsFold(nic.Applications.Values(), f.application) {
+		return false
+	}
+
+	if f.vendor != "" {
+		needle := strings.ToLower(f.vendor)
+
+		found := strings.Contains(strings.ToLower(oui.Vendor(nic.MAC)), needle)
+		for _, v := range nic.Vendor.Values() {
+			if strings.Contains(strings.ToLower(v), needle) {
+				found = true
+			}
+		}
+
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
+
+// intParam returns the query parameter name as an int, or def if not set.
+func intParam(r *http.Request, name string, def int) (int, error) {
+	value := r.URL.Query().Get(name)
+	if value == "" {
+		return def, nil
+	}
+
+	i, err := strconv.Atoi(value)
+	if err != nil || i < 0 {
+		return 0, strconv.ErrSyntax
+	}
+
+	return i, nil
+}
+
+func (a *api) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+
+	f := &filter{
+		mac:         q.Get("mac"),
+		ip:          q.Get("ip"),
+		hostname:    q.Get("hostname"),
+		application: q.Get("application"),
+		vendor:      q.Get("vendor"),
+	}
+
+	offset, err := intParam(r, "offset", 0)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "invalid offset")
+		return
+	}
+
+	limit, err := intParam(r, "limit", defaultLimit)
+	if err != nil || limit == 0 || limit > maxLimit {
+		writeError(w, http.StatusBadRequest, "limit must be between 1 and "+strconv.Itoa(maxLimit))
+		return
+	}
+
+	var matches []*intel.NIC
+
+	for _, nic := range a.intel.NICs() {
+		if f.match(nic) {
+			matches = append(matches, nic)
+		}
+	}
+
+	sort.Slice(matches, func(a, b int) bool {
+		return matches[a].MAC < matches[b].MAC
+	})
+
+	page := Page{
+		Total:  len(matches),
+		Offset: offset,
+		Limit:  limit,
+		NICs:   []NIC{},
+	}
+
+	for n := offset; n < len(matches) && n < offset+limit; n++ {
+		page.NICs = append(page.NICs, NIC{matches[n], oui.Vendor(matches[n].MAC)})
+	}
+
+	writeJSON(w, http.StatusOK, page)
+}
+
+func (a *api) get(w http.ResponseWriter, r *http.Request) {
+	nic := a.intel.NIC(strings.ToLower(r.PathValue("mac")))
+	if nic == nil {
+		writeError(w, http.StatusNotFound, "unknown station")
+		return
+	}
+
+	writeJSON(w, http.StatusOK, NIC{nic, oui.Vendor(nic.MAC)})
+}
@@ -0,0 +1,76 @@
+package capture
+
+import (
+	"os"
+	"sync"
+
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// linkLayerOffset makes absolute BPF loads relative to the link layer
+// header. Plain offsets are relative to the network header for received
+// packets at the point where packets are fanned out.
+const linkLayerOffset = 0x100000000 - 0x200000
+
+// Fanout is an AF_PACKET fanout group spreading the packets of one
+// interface over several sockets. Packets are spread by source MAC address,
+// so all packets from a station are received by the same socket, in order.
+type Fanout struct {
+	members int
+
+	mu sync.Mutex
+	id int
+}
+
+// NewFanout returns a Fanout for the given number of sockets.
+func NewFanout(members int) *Fanout {
+	return &Fanout{members: members, id: -1}
+}
+
+// program returns the program selecting a socket for a packet. It uses the
+// last four bytes of the source MAC address, which vary the most.
+func (f *Fanout) program() ([]bpf.RawInstruction, error) {
+	return bpf.Assemble([]bpf.Instruction{
+		bpf.LoadAbsolute{Off: linkLayerOffset + 8, Size: 4},
+		bpf.ALUOpConstant{Op: bpf.ALUOpMod, Val: uint32(f.members)},
+		bpf.RetA{},
+	})
+}
+
+// Join adds the bound packet socket fd to the group. The first socket
+// creates the group with an id chosen by the kernel.
+func (f *Fanout) Join(fd int) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.id >= 0 {
+		err := unix.SetsockoptInt(fd, unix.SOL_PACKET, unix.PACKET_FANOUT, f.id|unix.PACKET_FANOUT_CBPF<<16)
+
+		return os.NewSyscallError("setsockopt PACKET_FANOUT", err)
+	}
+
+	err := unix.SetsockoptInt(fd, unix.SOL_PACKET, unix.PACKET_FANOUT, (unix.PACKET_FANOUT_CBPF|unix.PACKET_FANOUT_FLAG_UNIQUEID)<<16)
+	if err != nil {
+		return os.NewSyscallError("setsockopt PACKET_FANOUT", err)
+	}
+
+	id, err := unix.GetsockoptInt(fd, unix.SOL_PACKET, unix.PACKET_FANOUT)
+	if err != nil {
+		return os.NewSyscallError("getsockopt PACKET_FANOUT", err)
+	}
+
+	program, err := f.program()
+	if err != nil {
+		return err
+	}
+
+	err = unix.SetsockoptSockFprog(fd, unix.SOL_PACKET, unix.PACKET_FANOUT_DATA, sockFprog(program))
+	if err != nil {
+		return os.NewSyscallError("setsockopt PACKET_FANOUT_DATA", err)
+	}
+
+	f.id = id & 0xffff
+
+	return nil
+}
@@ -0,0 +1,168 @@
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// pcapng block types and option codes.
+const (
+	ngBlockInterface      = 0x00000001
+	ngBlockEnhancedPacket = 0x00000006
+
+	ngOptionEnd         = 0
+	ngOptionComment     = 1
+	ngOptionShbUserAppl = 4
+	ngOptionIfName      = 2
+	ngOptionIfTsresol   = 9
+)
+
+type ngOption struct {
+	code  uint16
+	value []byte
+}
+
+// NgWriter writes pcapng files with an interface description block per
+// interface and optional packet comments. Files are written in little
+// endian with nanosecond timestamps.
+//
+// pcapgo.NgWriter can not write packet comments, which is why this exists.
+type NgWriter struct {
+	w          *bufio.Writer
+	interfaces int
+}
+
+// NewNgWriter writes a section header to w and returns a writer. A section
+// header may be written to the end of an existing pcapng file to append to
+// it.
+func NewNgWriter(w io.Writer, application string) (*NgWriter, error) {
+	ng := &NgWriter{w: bufio.NewWriter(w)}
+
+	body := make([]byte, 16)
+	binary.LittleEndian.PutUint32(body[0:4], 0x1a2b3c4d)
+	binary.LittleEndian.PutUint16(body[4:6], 1)
+	binary.LittleEndian.PutUint16(body[6:8], 0)
+
+	// The section length is unknown.
+	binary.LittleEndian.PutUint64(body[8:16], 0xffffffffffffffff)
+
+	var options []ngOption
+	if application != "" {
+		options = append(options, ngOption{ngOptionShbUserAppl, []byte(application)})
+	}
+
+	err := ng.writeBlock(ngSectionHeader, body, options)
+	if err != nil {
+		return nil, err
+	}
+
+	return ng, nil
+}
+
+// AddInterface writes an interface description block and returns the
+// interface ID to use with WritePacket.
+func (w *NgWriter) AddInterface(name string, linkType layers.LinkType) (int, error) {
+	body := make([]byte, 8)
+	binary.LittleEndian.PutUint16(body[0:2], uint16(linkType))
+	binary.LittleEndian.PutUint32(body[4:8], 0)
+
+	options := []ngOption{{ngOptionIfTsresol, []byte{9}}}
+	if name != "" {
+		options = append(options, ngOption{ngOptionIfName, []byte(name)})
+	}
+
+	err := w.writeBlock(ngBlockInterface, body, options)
+	if err != nil {
+		return 0, err
+	}
+
+	w.interfaces++
+
+	return w.interfaces - 1, nil
+}
+
+// WritePacket writes an enhanced packet block for the interface with the
+// given ID. comment is omitted if empty.
+func (w *NgWriter) WritePacket(id int, ci gopacket.CaptureInfo, data []byte, comment string) error {
+	if id < 0 || id >= w.interfaces {
+		return fmt.Errorf("unknown interface %d", id)
+	}
+
+	if ci.CaptureLength != len(data) {
+		return fmt.Errorf("capture length %d does not match data length %d", ci.CaptureLength, len(data))
+	}
+
+	length := ci.Length
+	if length < ci.CaptureLength {
+		length = ci.CaptureLength
+	}
+
+	ts := uint64(ci.Timestamp.UnixNano())
+
+	body := make([]byte, 20, 20+len(data)+3)
+	binary.LittleEndian.PutUint32(body[0:4], uint32(id))
+	binary.LittleEndian.PutUint32(body[4:8], uint32(ts>>32))
+	binary.LittleEndian.PutUint32(body[8:12], uint32(ts))
+	binary.LittleEndian.PutUint32(body[12:16], uint32(len(data)))
+	binary.LittleEndian.PutUint32(body[16:20], uint32(length))
+	body = append(body, data...)
+	body = append(body, make([]byte, pad(len(data)))...)
+
+	var options []ngOption
+	if comment != "" {
+		options = append(options, ngOption{ngOptionComment, []byte(comment)})
+	}
+
+	return w.writeBlock(ngBlockEnhancedPacket, body, options)
+}
+
+// Flush writes buffered data to the underlying writer.
+func (w *NgWriter) Flush() error {
+	return w.w.Flush()
+}
+
+// pad returns the padding needed to align n to 32 bits.
+func pad(n int) int {
+	return (4 - n%4) % 4
+}
+
+func (w *NgWriter) writeBlock(typ uint32, body []byte, options []ngOption) error {
+	var opts []byte
+
+	if len(options) > 0 {
+		for _, o := range options {
+			var header [4]byte
+			binary.LittleEndian.PutUint16(header[0:2], o.code)
+			binary.LittleEndian.PutUint16(header[2:4], uint16(len(o.value)))
+
+			opts = append(opts, header[:]...)
+			opts = append(opts, o.value...)
+			opts = append(opts, make([]byte, pad(len(o.value)))...)
+		}
+
+		opts = append(opts, 0, 0, 0, 0)
+	}
+
+	length := uint32(12 + len(body) + len(opts))
+
+	var header [8]byte
+	binary.LittleEndian.PutUint32(header[0:4], typ)
+	binary.LittleEndian.PutUint32(header[4:8], length)
+
+	var trailer [4]byte
+	binary.LittleEndian.PutUint32(trailer[:], length)
+
+	for _, b := range [][]byte{header[:], body, opts, trailer[:]} {
+		_, err := w.w.Write(b)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,142 @@
+// Package capture reads and writes packet captures in pcap and pcapng
+// format, and captures packets from a memory mapped ring on Linux.
+package capture
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+// Format is a capture file format.
+type Format int
+
+const (
+	// Pcap is the classic libpcap format.
+	Pcap Format = iota
+
+	// Pcapng is the pcap next generation format.
+	Pcapng
+)
+
+func (f Format) String() string {
+	if f == Pcapng {
+		return "pcapng"
+	}
+
+	return "pcap"
+}
+
+const ngSectionHeader = 0x0a0d0d0a
+
+// InterfaceName is added to the ancillary data of packets read from pcapng
+// files naming the interface the packet was captured on.
+type InterfaceName string
+
+// packetReader is implemented by pcapgo.Reader and pcapgo.NgReader.
+type packetReader interface {
+	ReadPacketData() ([]byte, gopacket.CaptureInfo, error)
+}
+
+// Reader reads packets from a pcap or pcapng stream.
+type Reader struct {
+	format   Format
+	reader   packetReader
+	ng       *pcapgo.NgReader
+	linkType layers.LinkType
+}
+
+// NewReader returns a Reader for r. The format is detected from the first
+// bytes.
+func NewReader(r io.Reader) (*Reader, error) {
+	br := bufio.NewReader(r)
+
+	magic, err := br.Peek(4)
+	if err != nil {
+		return nil, fmt.Errorf("reading capture header: %w", err)
+	}
+
+	if binary.LittleEndian.Uint32(magic) == ngSectionHeader {
+		ng, err := pcapgo.NewNgReader(br, pcapgo.NgReaderOptions{
+			WantMixedLinkType:  true,
+			SkipUnknownVersion: true,
+		})
+		if err != nil {
+			return nil, err
+		}
+
+		return &Reader{format: Pcapng, reader: ng, ng: ng}, nil
+	}
+
+	pcap, err := pcapgo.NewReader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Reader{format: Pcap, reader: pcap, linkType: pcap.LinkType()}, nil
+}
+
+// Format returns the detected format.
+func (r *Reader) Format() Format {
+	return r.format
+}
+
+// Interface returns the name of the pcapng interface with the given index
+// in the current section, or "" if unknown.
+func (r *Reader) Interface(index int) string {
+	if r.ng == nil {
+		return ""
+	}
+
+	intf, err := r.ng.Interface(index)
+	if err != nil {
+		return ""
+	}
+
+	return intf.Name
+}
+
+// ReadPacket returns the next packet decoded according to the link type of
+// its interface. It returns io.EOF at the end of the stream. If the
+// interface has a name, it is available as InterfaceName in the ancillary
+// data.
+func (r *Reader) ReadPacket() (gopacket.Packet, error) {
+	data, ci, linkType, err := r.ReadFrame()
+	if err != nil {
+		return nil, err
+	}
+
+	packet := gopacket.NewPacket(data, linkType, gopacket.Default)
+	packet.Metadata().CaptureInfo = ci
+
+	return packet, nil
+}
+
+// ReadFrame is like ReadPacket, but returns the undecoded packet data and
+// the link type of its interface.
+func (r *Reader) ReadFrame() ([]byte, gopacket.CaptureInfo, layers.LinkType, error) {
+	data, ci, err := r.reader.ReadPacketData()
+	if err != nil {
+		return nil, ci, 0, err
+	}
+
+	linkType := r.linkType
+	if len(ci.AncillaryData) > 0 {
+		if lt, ok := ci.AncillaryData[0].(layers.LinkType); ok {
+			linkType = lt
+		}
+
+		ci.AncillaryData = nil
+	}
+
+	if name := r.Interface(ci.InterfaceIndex); name != "" {
+		ci.AncillaryData = []interface{}{InterfaceName(name)}
+	}
+
+	return data, ci, linkType, nil
+}
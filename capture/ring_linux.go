@@ -0,0 +1,338 @@
+package capture
+
+import (
+	"errors"
+	"net"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+	"unsafe"
+
+	"github.com/google/gopacket"
+	"golang.org/x/net/bpf"
+	"golang.org/x/sys/unix"
+)
+
+// RingOptions configures a Ring. Zero values are replaced by defaults.
+type RingOptions struct {
+	// BlockSize is the size of each ring block in bytes. It must be a
+	// power of two multiple of the page size. It defaults to 1 MiB.
+	BlockSize int
+
+	// Blocks is the number of blocks in the ring. It defaults to 64.
+	Blocks int
+
+	// BlockTimeout is how long the kernel waits for a block to fill up
+	// before handing it over anyway. It defaults to 10 milliseconds.
+	BlockTimeout time.Duration
+
+	// Filter is attached to the socket if not empty.
+	Filter []bpf.RawInstruction
+
+	// Fanout is joined if not nil.
+	Fanout *Fanout
+}
+
+// RingStats are cumulative statistics of a Ring.
+type RingStats struct {
+	// Packets is the number of packets passing the filter, including
+	// dropped packets.
+	Packets uint64
+
+	// Drops is the number of packets dropped because the ring was full.
+	Drops uint64
+
+	// FreezeQueue is the number of times the ring was full.
+	FreezeQueue uint64
+}
+
+// Ring captures packets from an interface using a memory mapped
+// TPACKET_V3 ring shared with the kernel. Packets are handed over in
+// blocks, which saves a system call per packet.
+type Ring struct {
+	fd      int
+	ifindex int
+	ring    []byte
+
+	blockSize int
+	blocks    int
+
+	// block is the block being read. offset is the offset of the next
+	// packet in it and remaining the number of packets left.
+	block     int
+	reading   bool
+	offset    int
+	remaining uint32
+
+	// mu is held while reading, so Close can wait for the reader.
+	mu      sync.Mutex
+	stopped atomic.Bool
+	closed  atomic.Bool
+
+	statsMu sync.Mutex
+	stats   RingStats
+}
+
+// pollTimeout is how often a waiting reader checks if the ring is stopped
+// or closed.
+const pollTimeout = 100
+
+// htons converts a short from host to network byte order.
+func htons(v uint16) uint16 {
+	return v<<8 | v>>8
+}
+
+// NewRing returns a Ring capturing all packets on the interface ifname.
+func NewRing(ifname string, options RingOptions) (*Ring, error) {
+	intf, err := net.InterfaceByName(ifname)
+	if err != nil {
+		return nil, err
+	}
+
+	if options.BlockSize == 0 {
+		options.BlockSize = 1 << 20
+	}
+
+	if options.Blocks == 0 {
+		options.Blocks = 64
+	}
+
+	if options.BlockTimeout == 0 {
+		options.BlockTimeout = 10 * time.Millisecond
+	}
+
+	// No protocol until bound to the interface, to avoid receiving packets
+	// from other interfaces.
+	fd, err := unix.Socket(unix.AF_PACKET, unix.SOCK_RAW|unix.SOCK_CLOEXEC, 0)
+	if err != nil {
+		return nil, os.NewSyscallError("socket", err)
+	}
+
+	r := &Ring{
+		fd:        fd,
+		ifindex:   intf.Index,
+		blockSize: options.BlockSize,
+		blocks:    options.Blocks,
+	}
+
+	err = r.setup(options)
+	if err != nil {
+		if r.ring != nil {
+			_ = unix.Munmap(r.ring)
+		}
+		unix.Close(fd)
+
+		return nil, err
+	}
+
+	return r, nil
+}
+
+func (r *Ring) setup(options RingOptions) error {
+	err := unix.SetsockoptInt(r.fd, unix.SOL_PACKET, unix.PACKET_VERSION, unix.TPACKET_V3)
+	if err != nil {
+		return os.NewSyscallError("setsockopt PACKET_VERSION", err)
+	}
+
+	if len(options.Filter) > 0 {
+		err = unix.SetsockoptSockFprog(r.fd, unix.SOL_SOCKET, unix.SO_ATTACH_FILTER, sockFprog(options.Filter))
+		if err != nil {
+			return os.NewSyscallError("setsockopt SO_ATTACH_FILTER", err)
+		}
+	}
+
+	// Frames have no fixed size with TPACKET_V3, but the kernel insists
+	// on a sane frame size and count.
+	const frameSize = 1 << 11
+
+	err = unix.SetsockoptTpacketReq3(r.fd, unix.SOL_PACKET, unix.PACKET_RX_RING, &unix.TpacketReq3{
+		Block_size:     uint32(options.BlockSize),
+		Block_nr:       uint32(options.Blocks),
+		Frame_size:     frameSize,
+		Frame_nr:       uint32(options.BlockSize / frameSize * options.Blocks),
+		Retire_blk_tov: uint32(options.BlockTimeout / time.Millisecond),
+	})
+	if err != nil {
+		return os.NewSyscallError("setsockopt PACKET_RX_RING", err)
+	}
+
+	r.ring, err = unix.Mmap(r.fd, 0, options.BlockSize*options.Blocks, unix.PROT_READ|unix.PROT_WRITE, unix.MAP_SHARED)
+	if err != nil {
+		return os.NewSyscallError("mmap", err)
+	}
+
+	err = unix.Bind(r.fd, &unix.SockaddrLinklayer{
+		Protocol: htons(unix.ETH_P_ALL),
+		Ifindex:  r.ifindex,
+	})
+	if err != nil {
+		return os.NewSyscallError("bind", err)
+	}
+
+	if options.Fanout != nil {
+		return options.Fanout.Join(r.fd)
+	}
+
+	return nil
+}
+
+// sockFprog converts program for setsockopt.
+func sockFprog(program []bpf.RawInstruction) *unix.SockFprog {
+	filter := make([]unix.SockFilter, len(program))
+	for i, ins := range program {
+		filter[i] = unix.SockFilter{Code: ins.Op, Jt: ins.Jt, Jf: ins.Jf, K: ins.K}
+	}
+
+	return &unix.SockFprog{
+		Len:    uint16(len(filter)),
+		Filter: &filter[0],
+	}
+}
+
+// header returns the header of block.
+func (r *Ring) header(block int) *unix.TpacketHdrV1 {
+	// The header follows the version and private data offset fields of
+	// struct tpacket_block_desc.
+	return (*unix.TpacketHdrV1)(unsafe.Pointer(&r.ring[block*r.blockSize+8]))
+}
+
+// ReadPacketData returns the next packet, waiting for one if needed. The
+// data is only valid until the next call to ReadPacketData or Close.
+func (r *Ring) ReadPacketData() ([]byte, gopacket.CaptureInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for {
+		if r.stopped.Load() || r.closed.Load() {
+			return nil, gopacket.CaptureInfo{}, os.ErrClosed
+		}
+
+		if r.remaining > 0 {
+			return r.next()
+		}
+
+		// Hand the block back to the kernel and move on.
+		if r.reading {
+			atomic.StoreUint32(&r.header(r.block).Block_status, unix.TP_STATUS_KERNEL)
+
+			r.block = (r.block + 1) % r.blocks
+			r.reading = false
+		}
+
+		hdr := r.header(r.block)
+
+		if atomic.LoadUint32(&hdr.Block_status)&unix.TP_STATUS_USER == 0 {
+			err := r.wait()
+			if err != nil {
+				return nil, gopacket.CaptureInfo{}, err
+			}
+
+			continue
+		}
+
+		r.reading = true
+		r.offset = int(hdr.Offset_to_first_pkt)
+		r.remaining = hdr.Num_pkts
+	}
+}
+
+// next returns the packet at the current offset. It must be called with
+// the lock held and packets remaining in the block.
+func (r *Ring) next() ([]byte, gopacket.CaptureInfo, error) {
+	base := r.block*r.blockSize + r.offset
+	hdr := (*unix.Tpacket3Hdr)(unsafe.Pointer(&r.ring[base]))
+
+	start := base + int(hdr.Mac)
+	data := r.ring[start : start+int(hdr.Snaplen)]
+
+	ci := gopacket.CaptureInfo{
+		Timestamp:      time.Unix(int64(hdr.Sec), int64(hdr.Nsec)),
+		CaptureLength:  int(hdr.Snaplen),
+		Length:         int(hdr.Len),
+		InterfaceIndex: r.ifindex,
+	}
+
+	r.offset += int(hdr.Next_offset)
+	r.remaining--
+
+	return data, ci, nil
+}
+
+// wait waits until the kernel hands over a block, or pollTimeout passes. It
+// returns an error pending on the socket.
+func (r *Ring) wait() error {
+	fds := []unix.PollFd{{Fd: int32(r.fd), Events: unix.POLLIN | unix.POLLERR}}
+
+	_, err := unix.Poll(fds, pollTimeout)
+	if err != nil && !errors.Is(err, unix.EINTR) {
+		return os.NewSyscallError("poll", err)
+	}
+
+	// Errors are reported when the interface goes down or disappears.
+	if fds[0].Revents&unix.POLLERR != 0 {
+		errno, err := unix.GetsockoptInt(r.fd, unix.SOL_SOCKET, unix.SO_ERROR)
+		if err != nil {
+			return os.NewSyscallError("getsockopt SO_ERROR", err)
+		}
+
+		if errno != 0 {
+			return unix.Errno(errno)
+		}
+	}
+
+	return nil
+}
+
+// Stats returns the cumulative statistics of the ring.
+func (r *Ring) Stats() (RingStats, error) {
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+
+	if r.closed.Load() {
+		return r.stats, nil
+	}
+
+	// The kernel resets the counters on every read.
+	s, err := unix.GetsockoptTpacketStatsV3(r.fd, unix.SOL_PACKET, unix.PACKET_STATISTICS)
+	if err != nil {
+		return r.stats, os.NewSyscallError("getsockopt PACKET_STATISTICS", err)
+	}
+
+	r.stats.Packets += uint64(s.Packets)
+	r.stats.Drops += uint64(s.Drops)
+	r.stats.FreezeQueue += uint64(s.Freeze_q_cnt)
+
+	return r.stats, nil
+}
+
+// Stop makes ReadPacketData return os.ErrClosed within pollTimeout,
+// without releasing the ring. Unlike Close, it is safe while the data of
+// the last packet read is still in use, so a reader can be stopped from
+// another goroutine and close the ring itself.
+func (r *Ring) Stop() {
+	r.stopped.Store(true)
+}
+
+// Close releases the ring. A concurrent ReadPacketData returns
+// os.ErrClosed.
+func (r *Ring) Close() error {
+	if r.closed.Swap(true) {
+		return nil
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.statsMu.Lock()
+	defer r.statsMu.Unlock()
+
+	err := unix.Munmap(r.ring)
+	r.ring = nil
+
+	if cerr := unix.Close(r.fd); err == nil {
+		err = cerr
+	}
+
+	return err
+}
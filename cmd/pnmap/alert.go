@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+
+	"github.com/abrander/pnmap/alert"
+	"github.com/abrander/pnmap/intel"
+)
+
+var rulesFile string
+
+// loadRules reads the rules file given by --rules.
+func loadRules() (*alert.Rules, error) {
+	rules, err := alert.Load(rulesFile)
+	if err != nil {
+		return nil, err
+	}
+
+	if rules.NeedsStaleEvents() && staleAfter <= 0 {
+		return nil, fmt.Errorf("%s: matching NICStale events requires --stale-after or stale_after in the rule", rulesFile)
+	}
+
+	return rules, nil
+}
+
+// startAlerts starts an alert engine for the rules given by --rules. It
+// returns nil if no rules file is given.
+func startAlerts(i *intel.Intel, logger *slog.Logger) (*alert.Engine, error) {
+	if rulesFile == "" {
+		return nil, nil
+	}
+
+	rules, err := loadRules()
+	if err != nil {
+		return nil, err
+	}
+
+	engine := alert.New(rules, logger)
+	sub := i.Subscribe(1000)
+	engine.Track(i.NICs())
+	go engine.Run(sub)
+
+	return engine, nil
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"golang.org/x/net/bpf"
+)
+
+// groupTrafficFilter is a classic BPF program accepting the packets wanted
+// accepts, so the kernel can drop unicast traffic before it is copied to
+// pnmap. It understands up to two VLAN tags, 802.1Q or 802.1ad, and rejects
+// unicast frames with more, as nothing after the filter checks again.
+//
+// The program is equivalent to this tcpdump expression, with the tag
+// types of 802.1ad included:
+//
+//	not ether src 00:00:00:00:00:00 and (ether multicast or ip dst 255.255.255.255 or
+//	  ip dst net 224.0.0.0/24 or ip6 dst net ff02::/16 or
+//	  (vlan and (ip dst 255.255.255.255 or ip dst net 224.0.0.0/24 or ip6 dst net ff02::/16 or
+//	    (vlan and (ip dst 255.255.255.255 or ip dst net 224.0.0.0/24 or ip6 dst net ff02::/16))))
+var groupTrafficFilter = []bpf.Instruction{
+	// 0: Reject packets with an all zero source address.
+	bpf.LoadAbsolute{Off: 6, Size: 4},
+	bpf.JumpIf{Cond: bpf.JumpNotEqual, Val: 0, SkipTrue: 2},
+	bpf.LoadAbsolute{Off: 10, Size: 2},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0, SkipTrue: 8},
+
+	// 4: Accept group traffic.
+	bpf.LoadAbsolute{Off: 0, Size: 1},
+	bpf.JumpIf{Cond: bpf.JumpBitsSet, Val: 0x01, SkipTrue: 27},
+
+	// 6: Look at the IP destination of unicast frames. X is the length
+	// of the VLAN tags.
+	bpf.LoadConstant{Dst: bpf.RegX, Val: 0},
+	bpf.LoadAbsolute{Off: 12, Size: 2},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x0800, SkipTrue: 16},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x86dd, SkipTrue: 20},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x8100, SkipTrue: 2},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x88a8, SkipTrue: 1},
+	bpf.RetConstant{Val: 0},
+
+	// 13: One VLAN tag.
+	bpf.LoadConstant{Dst: bpf.RegX, Val: 4},
+	bpf.LoadAbsolute{Off: 16, Size: 2},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x0800, SkipTrue: 9},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x86dd, SkipTrue: 13},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x8100, SkipTrue: 2},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x88a8, SkipTrue: 1},
+	bpf.RetConstant{Val: 0},
+
+	// 20: Two VLAN tags. More tags are rejected.
+	bpf.LoadConstant{Dst: bpf.RegX, Val: 8},
+	bpf.LoadAbsolute{Off: 20, Size: 2},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x0800, SkipTrue: 2},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0x86dd, SkipTrue: 6},
+	bpf.RetConstant{Val: 0},
+
+	// 25: IPv4 broadcast or 224.0.0.0/24.
+	bpf.LoadIndirect{Off: 14 + 16, Size: 4},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0xffffffff, SkipTrue: 6},
+	bpf.ALUOpConstant{Op: bpf.ALUOpAnd, Val: 0xffffff00},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0xe0000000, SkipTrue: 4},
+	bpf.RetConstant{Val: 0},
+
+	// 30: IPv6 ff02::/16.
+	bpf.LoadIndirect{Off: 14 + 24, Size: 2},
+	bpf.JumpIf{Cond: bpf.JumpEqual, Val: 0xff02, SkipTrue: 1},
+	bpf.RetConstant{Val: 0},
+
+	// 33: Accept the whole packet.
+	bpf.RetConstant{Val: 0x40000},
+}
+
+// newFilterVM returns a VM running groupTrafficFilter in userspace, used
+// where the program cannot be attached to the socket.
+func newFilterVM() *bpf.VM {
+	vm, err := bpf.NewVM(groupTrafficFilter)
+	if err != nil {
+		// The program is static, this can only be a programming error.
+		panic(err)
+	}
+
+	return vm
+}
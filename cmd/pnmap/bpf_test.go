@@ -0,0 +1,144 @@
+package main
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/abrander/pnmap/capture"
+)
+
+func TestGroupTrafficFilter(t *testing.T) {
+	paths, err := filepath.Glob("testdata/*.pcap")
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no captures in testdata: %v", err)
+	}
+
+	vm := newFilterVM()
+
+	for _, path := range paths {
+		t.Run(filepath.Base(path), func(t *testing.T) {
+			f, err := os.Open(path)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+			defer f.Close()
+
+			reader, err := capture.NewReader(f)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+
+			var packets, accepted int
+
+			for n := 1; ; n++ {
+				packet, err := reader.ReadPacket()
+				if err != nil {
+					break
+				}
+
+				packets++
+
+				kernel, err := vm.Run(packet.Data())
+				if err != nil {
+					t.Fatalf("packet %d: %s", n, err)
+				}
+
+				userspace := wanted(packet)
+				if userspace {
+					accepted++
+				}
+
+				switch {
+				case kernel == 0 && userspace:
+					t.Errorf("packet %d wanted but rejected by BPF: %s", n, packet)
+
+				case kernel > 0 && !userspace:
+					t.Errorf("packet %d accepted by BPF but not wanted: %s", n, packet)
+				}
+			}
+
+			if packets == 0 || accepted == 0 || accepted == packets {
+				t.Errorf("%d packets with %d wanted, the capture does not exercise the filter", packets, accepted)
+			}
+		})
+	}
+}
+
+// TestGroupTrafficFilterUnicast checks unicast frames the captures do not
+// have, that the program and wanted must agree on.
+func TestGroupTrafficFilterUnicast(t *testing.T) {
+	src, _ := net.ParseMAC("02:00:00:00:00:01")
+	dst, _ := net.ParseMAC("02:00:00:00:00:02")
+
+	ipv4 := func(ip string) gopacket.SerializableLayer {
+		return &layers.IPv4{Version: 4, IHL: 5, TTL: 1, Protocol: layers.IPProtocolUDP, SrcIP: net.ParseIP("192.0.2.1"), DstIP: net.ParseIP(ip)}
+	}
+
+	ipv6 := func(ip string) gopacket.SerializableLayer {
+		return &layers.IPv6{Version: 6, HopLimit: 1, NextHeader: layers.IPProtocolNoNextHeader, SrcIP: net.ParseIP("fe80::1"), DstIP: net.ParseIP(ip)}
+	}
+
+	// tagged returns the layers of a frame with n VLAN tags around l.
+	tagged := func(n int, l gopacket.SerializableLayer) []gopacket.SerializableLayer {
+		typ := layers.EthernetTypeIPv4
+		if _, ok := l.(*layers.IPv6); ok {
+			typ = layers.EthernetTypeIPv6
+		}
+
+		frame := []gopacket.SerializableLayer{l}
+
+		for range n {
+			frame = append([]gopacket.SerializableLayer{&layers.Dot1Q{VLANIdentifier: 10, Type: typ}}, frame...)
+			typ = layers.EthernetTypeDot1Q
+		}
+
+		return append([]gopacket.SerializableLayer{&layers.Ethernet{SrcMAC: src, DstMAC: dst, EthernetType: typ}}, frame...)
+	}
+
+	cases := []struct {
+		name   string
+		frame  []gopacket.SerializableLayer
+		wanted bool
+	}{
+		{"broadcast", tagged(0, ipv4("255.255.255.255")), true},
+		{"link local multicast", tagged(0, ipv4("224.0.0.251")), true},
+		{"multicast", tagged(0, ipv4("239.255.255.250")), false},
+		{"unicast", tagged(0, ipv4("192.0.2.2")), false},
+		{"two tags", tagged(2, ipv4("255.255.255.255")), true},
+		{"three tags", tagged(3, ipv4("255.255.255.255")), false},
+		{"ipv6 link local", tagged(0, ipv6("ff02::fb")), true},
+		{"ipv6 two tags", tagged(2, ipv6("ff02::1")), true},
+		{"ipv6 transient link local", tagged(0, ipv6("ff12::1")), false},
+		{"ipv6 site local", tagged(1, ipv6("ff05::2")), false},
+		{"ipv6 three tags", tagged(3, ipv6("ff02::1")), false},
+	}
+
+	vm := newFilterVM()
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			buf := gopacket.NewSerializeBuffer()
+
+			err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true}, c.frame...)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+
+			kernel, err := vm.Run(buf.Bytes())
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+
+			userspace := wanted(gopacket.NewPacket(buf.Bytes(), layers.LayerTypeEthernet, gopacket.Default))
+
+			if (kernel > 0) != c.wanted || userspace != c.wanted {
+				t.Errorf("accepted by BPF: %t, wanted: %t, expected %t", kernel > 0, userspace, c.wanted)
+			}
+		})
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+// config is the optional configuration file.
+type config struct {
+	// Dissectors turns dissectors on or off by name.
+	Dissectors map[string]bool `json:"dissectors"`
+}
+
+var (
+	configFile = getConfigFile()
+
+	enableDissectors  []string
+	disableDissectors []string
+)
+
+func getConfigFile() string {
+	homedir, _ := os.UserHomeDir()
+	return fmt.Sprintf("%s/.pnmap/config.json", homedir)
+}
+
+// loadConfig reads the configuration from path. A missing file is not an
+// error.
+func loadConfig(path string) (*config, error) {
+	c := &config{}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return c, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	err = json.Unmarshal(data, c)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	return c, nil
+}
+
+// applyDissectors turns dissectors on and off according to c and the
+// command line flags. Flags take precedence. Dissectors not mentioned are
+// enabled. Nothing is changed if an unknown dissector is named, and
+// dissectors keeping their state are not touched.
+func (c *config) applyDissectors(i *intel.Intel) error {
+	dissectors := i.Dissectors()
+
+	enabled := make(map[string]bool, len(dissectors))
+	for _, d := range dissectors {
+		enabled[d.Name] = true
+	}
+
+	set := func(name string, on bool) error {
+		if _, found := enabled[name]; !found {
+			return fmt.Errorf("unknown dissector '%s'", name)
+		}
+
+		enabled[name] = on
+
+		return nil
+	}
+
+	for name, on := range c.Dissectors {
+		err := set(name, on)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range enableDissectors {
+		err := set(name, true)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, name := range disableDissectors {
+		err := set(name, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, d := range dissectors {
+		if d.Enabled != enabled[d.Name] {
+			_ = i.EnableDissector(d.Name, enabled[d.Name])
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/abrander/pnmap/alert"
+	"github.com/abrander/pnmap/intel"
+)
+
+var (
+	logFormat  string
+	logLevel   string
+	staleAfter time.Duration
+)
+
+func newLogger() (*slog.Logger, error) {
+	var level slog.Level
+
+	err := level.UnmarshalText([]byte(logLevel))
+	if err != nil {
+		return nil, err
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+
+	switch logFormat {
+	case "text":
+		return slog.New(slog.NewTextHandler(os.Stderr, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(os.Stderr, opts)), nil
+	}
+
+	return nil, fmt.Errorf("unknown log format '%s'", logFormat)
+}
+
+// logEvents logs all events from i until sub is closed.
+func logEvents(logger *slog.Logger, sub *intel.Subscription) {
+	for e := range sub.C {
+		level := slog.LevelInfo
+		if e.Type == intel.NICSeen {
+			level = slog.LevelDebug
+		}
+
+		logger.Log(context.Background(), level, e.Type.String(),
+			"mac", e.MAC,
+			"value", e.Value,
+			"source", e.Source,
+			"time", e.Time,
+		)
+	}
+}
+
+// reload rereads the configuration and rules files and reopens the unknown
+// packet file.
+func reload(logger *slog.Logger, i *intel.Intel, alerts *alert.Engine) {
+	conf, err := loadConfig(configFile)
+	if err == nil {
+		err = conf.applyDissectors(i)
+	}
+
+	if err != nil {
+		logger.Error("reloading configuration failed", "path", configFile, "error", err)
+	} else {
+		logger.Info("configuration reloaded", "path", configFile)
+	}
+
+	if alerts != nil {
+		rules, err := loadRules()
+		if err != nil {
+			logger.Error("reloading rules failed", "path", rulesFile, "error", err)
+		} else {
+			alerts.SetRules(rules)
+			logger.Info("rules reloaded", "path", rulesFile, "rules", len(rules.Rules))
+		}
+	}
+
+	if unknownWriter != nil {
+		err = unknownWriter.Reopen()
+		if err != nil {
+			logger.Error("reopening unknown packet file failed", "path", unknown, "error", err)
+		} else {
+			logger.Info("unknown packet file reopened", "path", unknown)
+		}
+	}
+}
+
+func daemon(_ *cobra.Command, _ []string) {
+	logger := startLogger()
+
+	packets := startListeners(logger)
+
+	i := newIntel(intel.WithNICs(loadState()), intel.WithStaleAfter(staleAfter))
+
+	logger.Info("pnmap started", "interfaces", listenInterfaces(), "from", sources, "state", statePath(), "stations", i.Len())
+
+	serve(logger, i, nil, nil, func() {
+		go packets.Run(i)
+	})
+}
+
+// startLogger sets up logging as given by --log-format and --log-level.
+func startLogger() *slog.Logger {
+	logger, err := newLogger()
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	slog.SetDefault(logger)
+
+	return logger
+}
+
+// serve starts logging, outputs, persistence and the HTTP server for i,
+// calls start, and runs until terminated. SIGHUP reloads the
+// configuration.
+func serve(logger *slog.Logger, i *intel.Intel, tlsConfig *tls.Config, extra map[string]http.Handler, start func()) {
+	go logEvents(logger, i.Subscribe(1000))
+
+	alerts, err := startAlerts(i, logger)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	err = startSyslog(i, logger)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	stopMQTT := startMQTT(i, logger)
+
+	p := newPersister(i, func(d time.Duration, err error) {
+		if err != nil {
+			logger.Error("saving state failed", "path", statePath(), "error", err)
+		} else {
+			logger.Debug("state saved", "path", statePath(), "duration", d)
+		}
+	})
+
+	stopServer := startServer(i, tlsConfig, extra)
+
+	start()
+
+	startClock(i)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT, syscall.SIGHUP)
+
+	for sig := range signals {
+		switch sig {
+		case syscall.SIGHUP:
+			reload(logger, i, alerts)
+
+		default:
+			logger.Info("shutting down", "signal", sig.String())
+			stopServer()
+			stopMQTT()
+
+			err := p.Stop()
+			if err != nil {
+				logger.Error("saving state failed", "path", statePath(), "error", err)
+			}
+
+			return
+		}
+	}
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+
+	"github.com/abrander/pnmap/export"
+	"github.com/abrander/pnmap/history"
+	"github.com/abrander/pnmap/intel"
+	"github.com/abrander/pnmap/state"
+)
+
+var (
+	exportState   string
+	exportFormat  string
+	exportColumns []string
+)
+
+func exportInventory(_ *cobra.Command, _ []string) {
+	var nics intel.NICCollection
+
+	// Exporting should not create or back up anything.
+	switch {
+	case database != "":
+		db, err := history.OpenReadOnly(database)
+		if err != nil {
+			log.Fatalf("opening %s: %s", database, err)
+		}
+
+		nics, err = db.NICs()
+		db.Close()
+
+		if err != nil {
+			log.Fatalf("reading %s: %s", database, err)
+		}
+
+	default:
+		f, err := state.Read(exportState)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		nics = f.NICs
+	}
+
+	err := export.Write(os.Stdout, exportFormat, exportColumns, export.Sorted(nics))
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+}
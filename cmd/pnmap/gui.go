@@ -0,0 +1,284 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/gdamore/tcell/v2"
+
+	"github.com/rivo/tview"
+
+	"github.com/abrander/pnmap/intel"
+	"github.com/abrander/pnmap/oui"
+)
+
+const ips = "ips"
+const hostnames = "hostnames"
+const useragents = "useragents"
+const vendor = "vendor"
+const applications = "applications"
+const seen = "seen"
+const lastseen = "lastseen"
+const firstseen = "firstseen"
+
+type gui struct {
+	app       *tview.Application
+	hostList  *tview.List
+	details   *tview.TextView
+	log       *tview.TextView
+	status    *tview.TextView
+	secondary string
+
+	nics map[string]*intel.NIC
+
+	// now returns the current time, which is the replay time when
+	// simulating.
+	now func() time.Time
+}
+
+func newGUI() *gui {
+	g := &gui{
+		app:       tview.NewApplication(),
+		hostList:  tview.NewList(),
+		details:   tview.NewTextView(),
+		log:       tview.NewTextView(),
+		status:    tview.NewTextView(),
+		secondary: ips,
+		nics:      make(map[string]*intel.NIC),
+		now:       time.Now,
+	}
+
+	flex := tview.NewFlex()
+
+	flex.SetDirection(tview.FlexColumn)
+	flex.AddItem(g.hostList, 0, 30, true)
+	flex.AddItem(g.details, 0, 70, false)
+
+	g.hostList.SetBorder(true)
+	g.hostList.SetBorderColor(tcell.ColorGray)
+	g.hostList.SetTitle(" Stations ")
+	g.hostList.SetTitleColor(tcell.ColorGreenYellow)
+	g.hostList.SetMainTextColor(tcell.ColorGreen)
+	g.hostList.SetSecondaryTextColor(tcell.ColorWhite)
+	g.hostList.SetSelectedTextColor(tcell.ColorBlack)
+	g.hostList.SetSelectedBackgroundColor(tcell.ColorGreen)
+
+	g.details.SetBorder(true)
+	g.details.SetBorderColor(tcell.ColorGray)
+	g.details.SetTitleColor(tcell.ColorGreenYellow)
+	g.details.SetTextColor(tcell.ColorWhite)
+	g.details.SetDynamicColors(true)
+
+	g.log.SetBorder(true)
+	g.log.SetBorderColor(tcell.ColorGray)
+	g.log.SetTitle(" Log ")
+	g.log.SetTitleColor(tcell.ColorGreenYellow)
+	g.log.SetTextColor(tcell.ColorWhite)
+	g.log.SetMaxLines(100)
+	g.log.ScrollToEnd()
+	g.log.SetChangedFunc(func() {
+		g.app.Draw()
+	})
+
+	g.status.SetTextColor(tcell.ColorGray)
+
+	root := tview.NewFlex()
+	root.SetDirection(tview.FlexRow)
+	root.AddItem(flex, 0, 1, true)
+	root.AddItem(g.log, 6, 0, false)
+	root.AddItem(g.status, 1, 0, false)
+
+	g.app.SetRoot(root, true)
+
+	g.app.SetInputCapture(func(event *tcell.EventKey) *tcell.EventKey {
+		switch event.Key() {
+		case tcell.KeyRune:
+			switch event.Rune() {
+			case '1':
+				g.secondary = ips
+			case '2':
+				g.secondary = hostnames
+			case '3':
+				g.secondary = useragents
+			case '4':
+				g.secondary = vendor
+			case '5':
+				g.secondary = applications
+			case '6':
+				g.secondary = seen
+			case '7':
+				g.secondary = lastseen
+			case '8':
+				g.secondary = firstseen
+			}
+
+			// The input capture runs in the event loop, so we can update
+			// the list directly.
+			for _, nic := range g.nics {
+				g.setNIC(nic)
+			}
+
+		}
+		return event
+	})
+
+	return g
+}
+
+// logger returns a logger writing to the log pane. Writing to the terminal
+// would mess up the interface.
+func (g *gui) logger() *slog.Logger {
+	return slog.New(slog.NewTextHandler(g.log, nil))
+}
+
+func (g *gui) Run() error {
+	return g.app.Run()
+}
+
+func (g *gui) selectHost() {
+	selected := g.hostList.GetCurrentItem()
+
+	mac, _ := g.hostList.GetItemText(selected)
+
+	nic, found := g.nics[mac[0:17]]
+
+	if !found {
+		// This should not happen
+		return
+	}
+
+	g.details.SetTitle(" " + nic.MAC + " ")
+	g.details.SetText(nicDetails(nic, g.now()))
+}
+
+// follow keeps the station list updated with changes from i. If events are
+// dropped, the whole list is refreshed from a snapshot.
+func (g *gui) follow(i *intel.Intel) {
+	sub := i.Subscribe(1000)
+	defer sub.Close()
+
+	for _, nic := range i.NICs() {
+		g.updateNIC(nic)
+	}
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	var dropped uint64
+
+	for {
+		select {
+		case e := <-sub.C:
+			g.updateNIC(e.NIC)
+
+		case <-ticker.C:
+			status := listenerStatus()
+			g.app.QueueUpdateDraw(func() {
+				g.status.SetText(status)
+			})
+
+			if d := sub.Dropped(); d != dropped {
+				dropped = d
+
+				for _, nic := range i.NICs() {
+					g.updateNIC(nic)
+				}
+			}
+		}
+	}
+}
+
+// updateNIC queues an update of a station. It is safe to call from any
+// goroutine. nic must not be changed after the call.
+func (g *gui) updateNIC(nic *intel.NIC) {
+	g.app.QueueUpdateDraw(func() {
+		g.setNIC(nic)
+	})
+}
+
+// setNIC updates the list with nic. It must only be called from the event
+// loop.
+func (g *gui) setNIC(nic *intel.NIC) {
+	var sec string
+
+	switch g.secondary {
+	default:
+		sec = fmt.Sprintf("  %v", nic.IPs.Values())
+	case ips:
+		sec = fmt.Sprintf("  %v", nic.IPs.Values())
+	case hostnames:
+		sec = fmt.Sprintf("  %v", nic.Hostnames.Values())
+	case useragents:
+		sec = fmt.Sprintf("  %v", nic.UserAgents.Values())
+	case vendor:
+		sec = fmt.Sprintf("  %v", nic.Vendor.Values())
+	case applications:
+		sec = fmt.Sprintf("  %v", nic.Applications.Values())
+	case seen:
+		sec = fmt.Sprintf("  %v", nic.Seen)
+	case lastseen:
+		sec = fmt.Sprintf("  %v", nic.LastSeen)
+	case firstseen:
+		sec = fmt.Sprintf("  %v", nic.FirstSeen)
+	}
+
+	g.nics[nic.MAC] = nic
+
+	selected := g.hostList.GetCurrentItem()
+
+	for i := 0; i < g.hostList.GetItemCount(); i++ {
+		main, _ := g.hostList.GetItemText(i)
+		if main[0:17] == nic.MAC {
+			g.hostList.RemoveItem(i)
+			g.hostList.InsertItem(i, main, sec, 0, g.selectHost)
+
+			g.hostList.SetCurrentItem(selected)
+
+			// If the current item is selected, update details view.
+			if selected == i {
+				g.selectHost()
+			}
+
+			return
+		}
+	}
+
+	g.hostList.AddItem(nic.MAC+" "+oui.Vendor(nic.MAC), sec, 0, g.selectHost)
+}
+
+// nicDetails returns a description of nic at now with color tags for the
+// details view.
+func nicDetails(n *intel.NIC, now time.Time) string {
+	output := ""
+
+	output += fmt.Sprintf("[yellow]First seen[reset]: [white]%s[reset] ([white]%s[reset] ago)\n", n.FirstSeen.UTC().String(), now.Sub(n.FirstSeen).Round(time.Second).String())
+	output += fmt.Sprintf("[yellow]Last seen[reset]: [white]%s[reset] ([white]%s[reset] ago)\n", n.LastSeen.UTC().String(), now.Sub(n.LastSeen).Round(time.Second).String())
+	output += fmt.Sprintf("[yellow]Packets[reset]: [white]%d[reset]\n\n", n.Seen)
+
+	output += fmt.Sprintf("[yellow]OUI Vendor[reset]: [white]%s[reset]\n", oui.Vendor(n.MAC))
+	output += fmt.Sprintf("[yellow]IPS[reset]:\n%s", observationDetails(n.IPs))
+	output += fmt.Sprintf("[yellow]Hostnames[reset]:\n%s", observationDetails(n.Hostnames))
+	output += fmt.Sprintf("[yellow]User agents[reset]:\n%s", observationDetails(n.UserAgents))
+	output += fmt.Sprintf("[yellow]Vendor[reset]:\n%s", observationDetails(n.Vendor))
+	output += fmt.Sprintf("[yellow]Applications[reset]:\n%s", observationDetails(n.Applications))
+
+	return output
+}
+
+// observationDetails returns the observations one per line with their
+// evidence.
+func observationDetails(o intel.Observations) string {
+	output := ""
+
+	for _, obs := range o {
+		source := obs.Source
+		if source == "" {
+			source = "unknown"
+		}
+
+		output += fmt.Sprintf("  [white]%s[reset] [gray](%s, %d×, last %s)[reset]\n", tview.Escape(obs.Value), source, obs.Count, obs.LastSeen.UTC().Format(time.RFC3339))
+	}
+
+	return output
+}
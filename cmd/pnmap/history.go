@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/abrander/pnmap/history"
+	"github.com/abrander/pnmap/oui"
+)
+
+var (
+	historyIP       string
+	historyHostname string
+)
+
+func showHistory(_ *cobra.Command, args []string) {
+	if database == "" {
+		log.Fatalf("--db is required")
+	}
+
+	db, err := history.OpenReadOnly(database)
+	if err != nil {
+		log.Fatalf("opening %s: %s", database, err)
+	}
+	defer db.Close()
+
+	var macs []string
+
+	switch {
+	case len(args) == 1:
+		macs = args
+
+	case historyIP != "":
+		macs, err = db.ByIP(historyIP)
+
+	case historyHostname != "":
+		macs, err = db.ByHostname(historyHostname)
+
+	default:
+		log.Fatalf("a MAC address, --ip or --hostname is required")
+	}
+
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	for _, mac := range macs {
+		nic, err := db.NIC(mac)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		if nic == nil {
+			fmt.Printf("%s: unknown\n", mac)
+			continue
+		}
+
+		fmt.Printf("%s %s\n", nic.MAC, oui.Vendor(nic.MAC))
+		fmt.Printf("  First seen: %s\n", nic.FirstSeen.UTC().Format(time.RFC3339))
+		fmt.Printf("  Last seen:  %s\n", nic.LastSeen.UTC().Format(time.RFC3339))
+		fmt.Printf("  Packets:    %d\n\n", nic.Seen)
+
+		records, err := db.History(mac)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, r := range records {
+			fmt.Fprintf(w, "  %s\t%s\t%s\t%s\n", r.Time.UTC().Format(time.RFC3339), r.Type, r.Source, r.Value)
+		}
+		w.Flush()
+
+		fmt.Println()
+	}
+}
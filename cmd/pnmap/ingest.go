@@ -0,0 +1,156 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/abrander/pnmap/export"
+	"github.com/abrander/pnmap/intel"
+	"github.com/abrander/pnmap/oui"
+	"github.com/abrander/pnmap/state"
+)
+
+var ingestState string
+
+// ingest merges captures into a state file. Captures are read one at a time
+// in the order given, so the result only depends on the captures and the
+// state file.
+func ingest(_ *cobra.Command, args []string) {
+	for _, a := range args {
+		if a == "-" {
+			continue
+		}
+
+		_, err := os.Stat(a)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+	}
+
+	f, err := state.Load(ingestState)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	before := f.NICs.Copy()
+
+	i := newIntel(intel.WithNICs(f.NICs))
+
+	var first, last time.Time
+
+	decoder := intel.NewDecoder()
+
+	for _, a := range args {
+		packets := make(chan frame, 10)
+
+		go func() {
+			// Captures cut short are common, keep what was read.
+			err := readCapture(a, func(f frame) { packets <- f })
+			if err != nil {
+				log.Printf("%s: %s", a, err)
+			}
+
+			close(packets)
+		}()
+
+		for f := range packets {
+			dissect(i, decoder, f)
+
+			ts := f.ci.Timestamp
+			if first.IsZero() || ts.Before(first) {
+				first = ts
+			}
+
+			if ts.After(last) {
+				last = ts
+			}
+		}
+	}
+
+	f.NICs = i.NICs()
+
+	err = state.Save(ingestState, f)
+	if err != nil {
+		log.Fatalf("saving %s: %s", ingestState, err)
+	}
+
+	printIngestSummary(len(args), i.Stats().Packets, first, last, before, f.NICs)
+}
+
+// newFacts returns what is known about after but not before, like
+// "ip 10.0.0.1".
+func newFacts(before *intel.NIC, after *intel.NIC) []string {
+	var facts []string
+
+	for _, field := range []struct {
+		name   string
+		before intel.Observations
+		after  intel.Observations
+	}{
+		{"ip", before.IPs, after.IPs},
+		{"hostname", before.Hostnames, after.Hostnames},
+		{"user-agent", before.UserAgents, after.UserAgents},
+		{"vendor", before.Vendor, after.Vendor},
+		{"application", before.Applications, after.Applications},
+	} {
+		for _, value := range field.after.Values() {
+			if !field.before.Contains(value) {
+				facts = append(facts, field.name+" "+value)
+			}
+		}
+	}
+
+	return facts
+}
+
+func printIngestSummary(captures int, packets uint64, first time.Time, last time.Time, before intel.NICCollection, after intel.NICCollection) {
+	fmt.Printf("Ingested %d packets from %d capture(s)", packets, captures)
+	if packets > 0 {
+		fmt.Printf(", %s to %s", first.UTC().Format(time.RFC3339), last.UTC().Format(time.RFC3339))
+	}
+	fmt.Printf("\n")
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+
+	var added, updated int
+
+	for _, nic := range export.Sorted(after) {
+		old, known := before[nic.MAC]
+
+		var status string
+		var facts []string
+
+		switch {
+		case !known:
+			status = "new"
+			facts = newFacts(&intel.NIC{}, nic)
+			added++
+
+		default:
+			facts = newFacts(old, nic)
+			if len(facts) == 0 {
+				continue
+			}
+
+			status = "updated"
+			updated++
+		}
+
+		if added+updated == 1 {
+			fmt.Printf("\n")
+			fmt.Fprintf(w, "STATUS\tMAC\tOUI VENDOR\tNEW\n")
+		}
+
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", status, nic.MAC, oui.Vendor(nic.MAC), strings.Join(facts, ", "))
+	}
+
+	w.Flush()
+
+	fmt.Printf("\n%d new, %d updated, %d stations in %s\n", added, updated, len(after), ingestState)
+}
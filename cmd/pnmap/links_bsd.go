@@ -0,0 +1,25 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"context"
+	"time"
+)
+
+// watchLinks signals changed every few seconds until ctx is done, so
+// interfaces appearing, disappearing or changing state are noticed.
+func watchLinks(ctx context.Context, changed chan<- struct{}) error {
+	ticker := time.NewTicker(5 * time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+
+		case <-ticker.C:
+			notify(changed)
+		}
+	}
+}
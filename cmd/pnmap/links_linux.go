@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// watchLinks signals changed whenever a network interface appears,
+// disappears or changes state, until ctx is done. Link changes are
+// announced by the kernel over netlink.
+func watchLinks(ctx context.Context, changed chan<- struct{}) error {
+	fd, err := unix.Socket(unix.AF_NETLINK, unix.SOCK_RAW|unix.SOCK_CLOEXEC|unix.SOCK_NONBLOCK, unix.NETLINK_ROUTE)
+	if err != nil {
+		return os.NewSyscallError("socket", err)
+	}
+
+	err = unix.Bind(fd, &unix.SockaddrNetlink{Family: unix.AF_NETLINK, Groups: unix.RTMGRP_LINK})
+	if err != nil {
+		unix.Close(fd)
+
+		return os.NewSyscallError("bind", err)
+	}
+
+	// A non-blocking file is read through the runtime poller, so closing it
+	// interrupts a read.
+	f := os.NewFile(uintptr(fd), "netlink")
+	defer closeWhenDone(ctx, f)()
+
+	buffer := make([]byte, 1<<16)
+
+	for {
+		_, err := f.Read(buffer)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		// Messages were lost, but something changed.
+		if err != nil && !errors.Is(err, unix.ENOBUFS) {
+			return err
+		}
+
+		// Only link messages are sent to the group, so there is no need to
+		// look at them. The interfaces are looked up afterwards.
+		notify(changed)
+	}
+}
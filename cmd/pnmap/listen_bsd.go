@@ -0,0 +1,87 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/google/gopacket/bsdbpf"
+)
+
+// checkCapture returns an error if the capture flags are not supported.
+func checkCapture() error {
+	if captureMode != "socket" {
+		return fmt.Errorf("capture method '%s' is only supported on Linux", captureMode)
+	}
+
+	if fanout > 1 {
+		return errors.New("--fanout is only supported on Linux")
+	}
+
+	return nil
+}
+
+// listen captures packets on deviceName until ctx is done or capturing
+// fails.
+func listen(ctx context.Context, deviceName string, out *pipeline, _ *slog.Logger) error {
+	options := &bsdbpf.Options{
+		ReadBufLen:       32767,
+		Promisc:          false,
+		Immediate:        true,
+		PreserveLinkAddr: true,
+
+		// Reads time out now and then, so ctx is checked.
+		Timeout: &syscall.Timeval{Sec: 1},
+	}
+
+	sniffer, err := bsdbpf.NewBPFSniffer(deviceName, options)
+	if err != nil {
+		return err
+	}
+	defer sniffer.Close()
+
+	stats := newInterfaceStats(deviceName)
+	vm := newFilterVM()
+
+	var ifindex int
+	if intf, err := net.InterfaceByName(deviceName); err == nil {
+		ifindex = intf.Index
+	}
+
+	for {
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		buffer, ci, err := sniffer.ReadPacketData()
+		if err != nil {
+			if e, ok := err.(syscall.Errno); ok && e.Temporary() {
+				continue
+			}
+
+			// This will happen from time to time - we simply continue and hope for the best :-)
+			if err.Error() == "BPF captured frame received with corrupted BpfHdr struct." {
+				continue
+			}
+
+			return err
+		}
+
+		n, err := vm.Run(buffer[0:ci.CaptureLength])
+		if err != nil || n == 0 {
+			continue
+		}
+
+		ci.InterfaceIndex = ifindex
+		ci.Timestamp = time.Now()
+
+		// The buffer is reused by the sniffer.
+		stats.deliver(out, frame{data: append([]byte(nil), buffer[0:ci.CaptureLength]...), ci: ci})
+	}
+}
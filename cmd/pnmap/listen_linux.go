@@ -0,0 +1,264 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"syscall"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/mdlayher/packet"
+	"golang.org/x/net/bpf"
+
+	"github.com/abrander/pnmap/capture"
+)
+
+// checkCapture returns an error if the capture flags are not supported.
+func checkCapture() error {
+	if captureMode != "socket" && captureMode != "mmap" {
+		return fmt.Errorf("unknown capture method '%s', use socket or mmap", captureMode)
+	}
+
+	return nil
+}
+
+// listen captures packets on deviceName until ctx is done or capturing
+// fails.
+func listen(ctx context.Context, deviceName string, out *pipeline, logger *slog.Logger) error {
+	listener := listenSocket
+	if captureMode == "mmap" {
+		listener = listenRing
+	}
+
+	if fanout <= 1 {
+		return listener(ctx, deviceName, nil, out, logger)
+	}
+
+	// Every socket in the group gets the packets of some stations. If one
+	// fails, they are all stopped, so the group can be started over.
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	group := capture.NewFanout(fanout)
+	errs := make(chan error, fanout)
+
+	for n := 0; n < fanout; n++ {
+		go func() {
+			errs <- listener(ctx, deviceName, group, out, logger)
+		}()
+	}
+
+	var err error
+
+	for n := 0; n < fanout; n++ {
+		e := <-errs
+		if e != nil && err == nil {
+			err = e
+			cancel()
+		}
+	}
+
+	return err
+}
+
+// watchDrops counts packets dropped by the kernel every second until ctx
+// is done. drops returns the number of packets dropped since it was last
+// called.
+func watchDrops(ctx context.Context, stats *interfaceStats, drops func() (uint64, error)) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+
+		case <-ticker.C:
+			n, err := drops()
+			if err != nil {
+				return
+			}
+
+			stats.Dropped(n)
+		}
+	}
+}
+
+// listenSocket reads packets one at a time from a raw socket.
+func listenSocket(ctx context.Context, deviceName string, group *capture.Fanout, out *pipeline, logger *slog.Logger) error {
+	intf, err := net.InterfaceByName(deviceName)
+	if err != nil {
+		return err
+	}
+
+	conn, err := packet.Listen(intf, packet.Raw, syscall.ETH_P_ALL, nil)
+	if err != nil {
+		return err
+	}
+
+	defer closeWhenDone(ctx, conn)()
+
+	if group != nil {
+		err = joinFanout(conn, group)
+		if err != nil {
+			return fmt.Errorf("joining fanout group: %w", err)
+		}
+	}
+
+	// Let the kernel drop unicast traffic. If that fails, run the same
+	// program here, which is still cheaper than decoding every packet.
+	var vm *bpf.VM
+
+	program, err := bpf.Assemble(groupTrafficFilter)
+	if err == nil {
+		err = conn.SetBPF(program)
+	}
+
+	if err != nil {
+		logger.Warn("attaching BPF filter failed, filtering in userspace", "interface", deviceName, "error", err)
+
+		vm = newFilterVM()
+	}
+
+	buffer := make([]byte, 65536)
+	stats := newInterfaceStats(deviceName)
+
+	go watchDrops(ctx, stats, func() (uint64, error) {
+		s, err := conn.Stats()
+		if err != nil {
+			return 0, err
+		}
+
+		return uint64(s.Drops), nil
+	})
+
+	for {
+		l, _, err := conn.ReadFrom(buffer)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		// Count the same packets whether filtered by the kernel or here.
+		if vm != nil {
+			n, err := vm.Run(buffer[0:l])
+			if err != nil || n == 0 {
+				continue
+			}
+		}
+
+		data := make([]byte, l)
+		copy(data, buffer)
+
+		stats.deliver(out, frame{
+			data: data,
+			ci: gopacket.CaptureInfo{
+				Timestamp:      time.Now(),
+				CaptureLength:  l,
+				Length:         l,
+				InterfaceIndex: intf.Index,
+			},
+		})
+	}
+}
+
+// joinFanout adds conn to group.
+func joinFanout(conn *packet.Conn, group *capture.Fanout) error {
+	rc, err := conn.SyscallConn()
+	if err != nil {
+		return err
+	}
+
+	cerr := rc.Control(func(fd uintptr) {
+		err = group.Join(int(fd))
+	})
+	if cerr != nil {
+		return cerr
+	}
+
+	return err
+}
+
+// listenRing reads packets from a memory mapped ring shared with the
+// kernel. Packets are timestamped by the kernel.
+func listenRing(ctx context.Context, deviceName string, group *capture.Fanout, out *pipeline, logger *slog.Logger) error {
+	options := capture.RingOptions{
+		BlockSize: 1 << 20,
+		Blocks:    ringSize,
+		Fanout:    group,
+	}
+
+	// --ring-size is per interface, so it is split between the sockets of
+	// a fanout group.
+	if group != nil {
+		options.Blocks = max(ringSize/fanout, 1)
+	}
+
+	var vm *bpf.VM
+
+	program, err := bpf.Assemble(groupTrafficFilter)
+	if err == nil {
+		options.Filter = program
+	}
+
+	ring, err := capture.NewRing(deviceName, options)
+
+	var syscallErr *os.SyscallError
+	if errors.As(err, &syscallErr) && syscallErr.Syscall == "setsockopt SO_ATTACH_FILTER" {
+		logger.Warn("attaching BPF filter failed, filtering in userspace", "interface", deviceName, "error", err)
+
+		options.Filter = nil
+		vm = newFilterVM()
+
+		ring, err = capture.NewRing(deviceName, options)
+	}
+
+	if err != nil {
+		return err
+	}
+
+	// The data read points into the ring, so only this goroutine may
+	// unmap it. Stopping the ring makes the read below return.
+	defer ring.Close()
+	defer context.AfterFunc(ctx, ring.Stop)()
+
+	stats := newInterfaceStats(deviceName)
+
+	var previous uint64
+
+	go watchDrops(ctx, stats, func() (uint64, error) {
+		s, err := ring.Stats()
+		n := s.Drops - previous
+		previous = s.Drops
+
+		return n, err
+	})
+
+	for {
+		data, ci, err := ring.ReadPacketData()
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if vm != nil {
+			n, err := vm.Run(data)
+			if err != nil || n == 0 {
+				continue
+			}
+		}
+
+		// The data is only valid until the next read.
+		stats.deliver(out, frame{data: append([]byte(nil), data...), ci: ci})
+	}
+}
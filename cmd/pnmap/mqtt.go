@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/abrander/pnmap/intel"
+	"github.com/abrander/pnmap/mqtt"
+)
+
+var (
+	mqttBroker       string
+	mqttPrefix       string
+	mqttDiscovery    bool
+	mqttTrack        []string
+	mqttConsiderHome time.Duration
+)
+
+// startMQTT starts publishing to the broker given by --mqtt, if any. It
+// returns a function stopping the publisher.
+func startMQTT(i *intel.Intel, logger *slog.Logger) func() {
+	if mqttBroker == "" {
+		return func() {}
+	}
+
+	p := mqtt.New(i, mqtt.Config{
+		Broker:       mqttBroker,
+		Prefix:       mqttPrefix,
+		Discovery:    mqttDiscovery,
+		Track:        mqttTrack,
+		ConsiderHome: mqttConsiderHome,
+	})
+
+	p.OnError = func(err error) {
+		logger.Error("publishing to MQTT failed", "error", err)
+	}
+
+	p.Start()
+
+	return p.Stop
+}
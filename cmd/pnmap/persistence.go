@@ -0,0 +1,111 @@
+package main
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/abrander/pnmap/history"
+	"github.com/abrander/pnmap/intel"
+	"github.com/abrander/pnmap/state"
+)
+
+var (
+	database  string
+	historyDB *history.DB
+)
+
+// persister is implemented by state.Persister and history.Recorder.
+type persister interface {
+	Stop() error
+}
+
+// statePath returns the path stations are saved to.
+func statePath() string {
+	if database != "" {
+		return database
+	}
+
+	return statefile
+}
+
+// loadState returns the saved stations. If a history database is used, it
+// is opened here. An empty database is seeded from the state file.
+func loadState() intel.NICCollection {
+	f, err := state.Load(statefile)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if database == "" {
+		return f.NICs
+	}
+
+	historyDB, err = history.Open(database)
+	if err != nil {
+		log.Fatalf("opening %s: %s", database, err)
+	}
+
+	nics, err := historyDB.NICs()
+	if err != nil {
+		log.Fatalf("reading %s: %s", database, err)
+	}
+
+	if len(nics) == 0 && len(f.NICs) > 0 {
+		err = historyDB.PutNICs(f.NICs)
+		if err != nil {
+			log.Fatalf("importing %s into %s: %s", statefile, database, err)
+		}
+
+		nics = f.NICs
+	}
+
+	return nics
+}
+
+// historyPersister closes the database after the last write.
+type historyPersister struct {
+	*history.Recorder
+}
+
+func (h historyPersister) Stop() error {
+	err := h.Recorder.Stop()
+
+	if cerr := historyDB.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// newPersister returns a started persister saving the stations of i to the
+// history database if used, or the state file. onSave is called after every
+// save if not nil.
+func newPersister(i *intel.Intel, onSave func(time.Duration, error)) persister {
+	saved := func(d time.Duration, err error) {
+		sensorMetrics.StateSaved(d, err)
+
+		if onSave != nil {
+			onSave(d, err)
+		}
+	}
+
+	if historyDB != nil {
+		r := history.NewRecorder(historyDB, i, time.Second)
+		r.OnWrite = saved
+		r.Start()
+
+		return historyPersister{r}
+	}
+
+	p := state.NewPersister(statefile, i, 10*time.Second)
+
+	p.Sensor.Hostname, _ = os.Hostname()
+	p.Sensor.Interfaces = *interfaces
+	p.Network.Gateway, p.Network.GatewayMAC = networkIdentity()
+	p.OnSave = saved
+
+	p.Start()
+
+	return p
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"sync"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+// pipeline dissects frames in a number of workers. Frames are assigned to
+// workers by source MAC address, so frames from a station are dissected in
+// the order they were captured.
+type pipeline struct {
+	queues []chan frame
+}
+
+// newPipeline returns a pipeline with workers workers, each queueing up to
+// depth frames.
+func newPipeline(workers int, depth int) *pipeline {
+	p := &pipeline{
+		queues: make([]chan frame, max(workers, 1)),
+	}
+
+	for n := range p.queues {
+		p.queues[n] = make(chan frame, depth)
+	}
+
+	return p
+}
+
+// queue returns the queue of the worker dissecting frames from the source
+// MAC address of data.
+func (p *pipeline) queue(data []byte) chan frame {
+	if len(data) < 12 || len(p.queues) == 1 {
+		return p.queues[0]
+	}
+
+	// FNV-1a.
+	h := uint32(2166136261)
+	for _, b := range data[6:12] {
+		h ^= uint32(b)
+		h *= 16777619
+	}
+
+	return p.queues[h%uint32(len(p.queues))]
+}
+
+// Offer queues f without waiting. It returns false if the queue is full
+// and f was dropped, which keeps a busy worker from stalling a capture.
+func (p *pipeline) Offer(f frame) bool {
+	select {
+	case p.queue(f.data) <- f:
+		return true
+	default:
+		return false
+	}
+}
+
+// Put queues f, waiting for room if the queue is full.
+func (p *pipeline) Put(f frame) {
+	p.queue(f.data) <- f
+}
+
+// Run dissects queued frames with i in the workers.
+func (p *pipeline) Run(i *intel.Intel) {
+	var wg sync.WaitGroup
+
+	for _, q := range p.queues {
+		wg.Add(1)
+
+		go func(q chan frame) {
+			defer wg.Done()
+
+			decoder := intel.NewDecoder()
+
+			for f := range q {
+				sensorMetrics.PacketProcessed(dissect(i, decoder, f))
+			}
+		}(q)
+	}
+
+	wg.Wait()
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/abrander/pnmap/api"
+	"github.com/abrander/pnmap/intel"
+	"github.com/abrander/pnmap/state"
+)
+
+// replayed is the capture the tests replay through the pipeline.
+const replayed = "../../intel/testdata/discovery.pcap"
+
+// simulateCaptures replays the capture rounds times through a pipeline
+// dissecting with i, like the simulate command. Every round is an hour
+// later than the one before, so stations go stale and come back.
+func simulateCaptures(t *testing.T, i *intel.Intel, workers int, rounds int) {
+	t.Helper()
+
+	p := newPipeline(workers, 16)
+
+	done := make(chan struct{})
+
+	go func() {
+		p.Run(i)
+		close(done)
+	}()
+
+	for round := range rounds {
+		err := readCapture(replayed, func(f frame) {
+			f.ci.Timestamp = f.ci.Timestamp.Add(time.Duration(round) * time.Hour)
+			p.Put(f)
+		})
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+	}
+
+	for _, q := range p.queues {
+		close(q)
+	}
+
+	<-done
+}
+
+// TestPipelineReaders dissects a capture in parallel workers while
+// subscribers, the state persister and the API read the stations. Run it
+// with -race.
+func TestPipelineReaders(t *testing.T) {
+	i := intel.New(intel.WithStaleAfter(30 * time.Minute))
+
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	persister := state.NewPersister(path, i, time.Millisecond)
+	persister.Start()
+
+	server := httptest.NewServer(api.New(i))
+	defer server.Close()
+
+	stop := make(chan struct{})
+
+	var wg sync.WaitGroup
+
+	// Subscribers read the copies in events, and the stations they name.
+	events := make([]int, 2)
+
+	for n := range events {
+		sub := i.Subscribe(10)
+
+		wg.Add(2)
+
+		go func() {
+			defer wg.Done()
+
+			for e := range sub.C {
+				events[n]++
+
+				if e.NIC != nil {
+					_ = e.NIC.String()
+				}
+
+				if nic := i.NIC(e.MAC); nic != nil {
+					_ = nic.String()
+				}
+			}
+		}()
+
+		go func() {
+			defer wg.Done()
+
+			<-stop
+			sub.Close()
+		}()
+	}
+
+	wg.Add(1)
+
+	go func() {
+		defer wg.Done()
+
+		for {
+			select {
+			case <-stop:
+				return
+
+			default:
+			}
+
+			resp, err := http.Get(server.URL + "/api/nics")
+			if err != nil {
+				t.Errorf("%s", err)
+
+				return
+			}
+
+			var page struct {
+				NICs []struct {
+					MAC string
+				}
+			}
+
+			err = json.NewDecoder(resp.Body).Decode(&page)
+			resp.Body.Close()
+
+			if err != nil {
+				t.Errorf("%s", err)
+
+				return
+			}
+
+			for _, nic := range page.NICs {
+				resp, err := http.Get(server.URL + "/api/nics/" + nic.MAC)
+				if err != nil {
+					t.Errorf("%s", err)
+
+					return
+				}
+				resp.Body.Close()
+			}
+		}
+	}()
+
+	simulateCaptures(t, i, 4, 10)
+
+	close(stop)
+	wg.Wait()
+
+	err := persister.Stop()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	for n, count := range events {
+		if count == 0 {
+			t.Errorf("subscriber %d got no events", n)
+		}
+	}
+
+	saved, err := state.Load(path)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(saved.NICs) == 0 || len(saved.NICs) != i.Len() {
+		t.Errorf("saved %d stations, expected %d", len(saved.NICs), i.Len())
+	}
+}
+
+// TestPipelineWorkers checks that the stations found do not depend on the
+// number of workers.
+func TestPipelineWorkers(t *testing.T) {
+	one := intel.New()
+	simulateCaptures(t, one, 1, 1)
+
+	many := intel.New()
+	simulateCaptures(t, many, 4, 1)
+
+	if one.Len() == 0 {
+		t.Fatal("no stations found")
+	}
+
+	expected, _ := json.Marshal(one.NICs())
+	found, _ := json.Marshal(many.NICs())
+
+	if string(expected) != string(found) {
+		t.Errorf("stations differ with 4 workers")
+	}
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bytes"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/abrander/pnmap/intel"
+	"github.com/abrander/pnmap/remote"
+)
+
+var (
+	collectorURL string
+	sensorName   string
+	sensorBuffer int
+
+	keyFile     string
+	tlsCert     string
+	tlsKey      string
+	tlsCA       string
+	tlsClientCA string
+)
+
+// loadKey returns the shared key from --key-file, or nil if not given.
+func loadKey() []byte {
+	if keyFile == "" {
+		return nil
+	}
+
+	key, err := os.ReadFile(keyFile)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	key = bytes.TrimSpace(key)
+	if len(key) == 0 {
+		log.Fatalf("%s: empty key", keyFile)
+	}
+
+	return key
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	pem, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("%s: no certificates found", path)
+	}
+
+	return pool, nil
+}
+
+// loadCertificates returns the certificate from --tls-cert and --tls-key,
+// if given.
+func loadCertificates() ([]tls.Certificate, error) {
+	if tlsCert == "" && tlsKey == "" {
+		return nil, nil
+	}
+
+	cert, err := tls.LoadX509KeyPair(tlsCert, tlsKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return []tls.Certificate{cert}, nil
+}
+
+func sensor(_ *cobra.Command, _ []string) {
+	logger := startLogger()
+
+	certificates, err := loadCertificates()
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	tlsConfig := &tls.Config{Certificates: certificates}
+
+	if tlsCA != "" {
+		tlsConfig.RootCAs, err = loadCertPool(tlsCA)
+		if err != nil {
+			log.Fatalf("%s", err)
+		}
+	}
+
+	client := &http.Client{
+		Transport: &http.Transport{
+			Proxy:           http.ProxyFromEnvironment,
+			TLSClientConfig: tlsConfig,
+		},
+	}
+
+	packets := startListeners(logger)
+
+	i := newIntel()
+
+	s := remote.NewSensor(collectorURL, sensorName, i, client)
+	s.Key = loadKey()
+	s.MaxBuffer = sensorBuffer
+	s.OnError = func(err error) {
+		logger.Warn("sending to collector failed", "collector", collectorURL, "error", err, "buffered", s.Buffered(), "dropped", s.Dropped())
+	}
+
+	s.Start()
+
+	logger.Info("sensor started", "name", sensorName, "interfaces", listenInterfaces(), "from", sources, "collector", collectorURL)
+
+	go packets.Run(i)
+
+	startClock(i)
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGTERM, syscall.SIGINT)
+
+	sig := <-signals
+	logger.Info("shutting down", "signal", sig.String(), "buffered", s.Buffered())
+
+	err = s.Stop(10 * time.Second)
+	if err != nil {
+		logger.Error("sending buffered records failed", "error", err, "buffered", s.Buffered())
+	}
+}
+
+func collector(_ *cobra.Command, _ []string) {
+	logger := startLogger()
+
+	if listenAddr == "" {
+		log.Fatalf("--listen is required")
+	}
+
+	certificates, err := loadCertificates()
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	var tlsConfig *tls.Config
+
+	if certificates != nil {
+		tlsConfig = &tls.Config{Certificates: certificates}
+
+		if tlsClientCA != "" {
+			tlsConfig.ClientCAs, err = loadCertPool(tlsClientCA)
+			if err != nil {
+				log.Fatalf("%s", err)
+			}
+
+			tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	} else if tlsClientCA != "" {
+		log.Fatalf("--tls-client-ca requires --tls-cert and --tls-key")
+	}
+
+	key := loadKey()
+	if key == nil && tlsClientCA == "" {
+		log.Fatalf("sensors must be authenticated, use --key-file or --tls-client-ca")
+	}
+
+	i := newIntel(intel.WithNICs(loadState()), intel.WithStaleAfter(staleAfter))
+
+	logger.Info("collector started", "listen", listenAddr, "state", statePath(), "stations", i.Len())
+
+	serve(logger, i, tlsConfig, map[string]http.Handler{
+		"/ingest": remote.NewCollector(i, key),
+	}, func() {})
+}
+
+func hostname() string {
+	name, _ := os.Hostname()
+
+	return name
+}
@@ -0,0 +1,212 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// replayer paces packets from a capture according to their timestamps and
+// filters them by time and station.
+type replayer struct {
+	// speed is the replay speed relative to the capture. Zero replays as
+	// fast as possible.
+	speed float64
+
+	// from and to limit the replay to packets in [from, to). They are
+	// either absolute, or relative to the first packet if fromOffset or
+	// toOffset are set.
+	from       time.Time
+	to         time.Time
+	fromOffset time.Duration
+	toOffset   time.Duration
+	relative   bool
+
+	macs map[string]bool
+
+	mu sync.Mutex
+
+	// first is the timestamp of the first packet in the capture.
+	first time.Time
+
+	// start is the capture time and wall time the replay started at.
+	start     time.Time
+	startWall time.Time
+
+	// last is the timestamp of the last packet replayed.
+	last time.Time
+}
+
+// parseSpeed parses speeds like "1x", "10x", "0.5x" or "max".
+func parseSpeed(s string) (float64, error) {
+	if s == "max" {
+		return 0, nil
+	}
+
+	speed, err := strconv.ParseFloat(strings.TrimSuffix(s, "x"), 64)
+	if err != nil || speed <= 0 {
+		return 0, fmt.Errorf("invalid speed '%s', use a factor like 1x or 10x, or max", s)
+	}
+
+	return speed, nil
+}
+
+// parseReplayTime parses an RFC 3339 timestamp, or a duration relative to
+// the start of the capture.
+func parseReplayTime(s string) (time.Time, time.Duration, bool, error) {
+	ts, err := time.Parse(time.RFC3339, s)
+	if err == nil {
+		return ts, 0, false, nil
+	}
+
+	offset, err := time.ParseDuration(s)
+	if err == nil {
+		return time.Time{}, offset, true, nil
+	}
+
+	return time.Time{}, 0, false, fmt.Errorf("invalid time '%s', use RFC 3339 like 2024-05-01T12:00:00Z or an offset like 10m", s)
+}
+
+func newReplayer(speed string, from string, to string, macs []string) (*replayer, error) {
+	r := &replayer{}
+
+	var err error
+
+	r.speed, err = parseSpeed(speed)
+	if err != nil {
+		return nil, err
+	}
+
+	var fromRelative, toRelative bool
+
+	if from != "" {
+		r.from, r.fromOffset, fromRelative, err = parseReplayTime(from)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if to != "" {
+		r.to, r.toOffset, toRelative, err = parseReplayTime(to)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if from != "" && to != "" && fromRelative != toRelative {
+		return nil, fmt.Errorf("--from and --to must both be timestamps or both be offsets")
+	}
+
+	r.relative = fromRelative || toRelative
+
+	if len(macs) > 0 {
+		r.macs = make(map[string]bool, len(macs))
+
+		for _, m := range macs {
+			mac, err := net.ParseMAC(m)
+			if err != nil {
+				return nil, err
+			}
+
+			r.macs[mac.String()] = true
+		}
+	}
+
+	return r, nil
+}
+
+// Now returns the current time of the replay. When replaying as fast as
+// possible, it is the timestamp of the last packet.
+func (r *replayer) Now() time.Time {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.startWall.IsZero() {
+		return time.Now()
+	}
+
+	if r.speed == 0 {
+		return r.last
+	}
+
+	return r.start.Add(time.Duration(float64(time.Since(r.startWall)) * r.speed))
+}
+
+// match returns true if f is within the time window and from or to a
+// selected station.
+func (r *replayer) match(f frame) bool {
+	ts := f.ci.Timestamp
+
+	if r.first.IsZero() {
+		r.first = ts
+
+		if r.relative {
+			if r.fromOffset != 0 {
+				r.from = r.first.Add(r.fromOffset)
+			}
+
+			if r.toOffset != 0 {
+				r.to = r.first.Add(r.toOffset)
+			}
+		}
+	}
+
+	if !r.from.IsZero() && ts.Before(r.from) {
+		return false
+	}
+
+	if !r.to.IsZero() && !ts.Before(r.to) {
+		return false
+	}
+
+	if r.macs == nil {
+		return true
+	}
+
+	if len(f.data) < 12 {
+		return false
+	}
+
+	return r.macs[net.HardwareAddr(f.data[6:12]).String()] || r.macs[net.HardwareAddr(f.data[0:6]).String()]
+}
+
+// run passes matching frames from in to out, waiting between them
+// according to their timestamps and the speed. out is closed when in is.
+func (r *replayer) run(in <-chan frame, out chan<- frame) {
+	defer close(out)
+
+	for f := range in {
+		if !r.match(f) {
+			continue
+		}
+
+		ts := f.ci.Timestamp
+
+		r.mu.Lock()
+		if r.startWall.IsZero() {
+			r.start = ts
+			r.startWall = time.Now()
+		}
+		start := r.start
+		startWall := r.startWall
+		r.mu.Unlock()
+
+		if r.speed > 0 {
+			due := startWall.Add(time.Duration(float64(ts.Sub(start)) / r.speed))
+			if wait := time.Until(due); wait > 0 {
+				time.Sleep(wait)
+			}
+		}
+
+		r.mu.Lock()
+		if ts.After(r.last) {
+			r.last = ts
+		}
+		r.mu.Unlock()
+
+		out <- f
+	}
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/abrander/pnmap/api"
+	"github.com/abrander/pnmap/intel"
+	"github.com/abrander/pnmap/metrics"
+)
+
+var (
+	listenAddr string
+
+	sensorMetrics = metrics.New()
+)
+
+// startServer starts the HTTP server if --listen is given. extra is mounted
+// next to the API, and the server uses TLS if tlsConfig is not nil. It
+// returns a function shutting the server down.
+func startServer(i *intel.Intel, tlsConfig *tls.Config, extra map[string]http.Handler) func() {
+	if listenAddr == "" {
+		return func() {}
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", api.New(i))
+
+	for pattern, handler := range extra {
+		mux.Handle(pattern, handler)
+	}
+
+	sensorMetrics.Attach(i)
+	mux.Handle("/metrics", sensorMetrics.Handler())
+
+	l, err := net.Listen("tcp", listenAddr)
+	if err != nil {
+		log.Fatalf("%s", err)
+	}
+
+	if tlsConfig != nil {
+		l = tls.NewListener(l, tlsConfig)
+	}
+
+	server := &http.Server{
+		Handler:           mux,
+		ReadHeaderTimeout: 10 * time.Second,
+	}
+
+	go func() {
+		err := server.Serve(l)
+		if err != nil && err != http.ErrServerClosed {
+			log.Printf("http server: %s", err)
+		}
+	}()
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		_ = server.Shutdown(ctx)
+	}
+}
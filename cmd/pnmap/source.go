@@ -0,0 +1,81 @@
+package main
+
+import (
+	"io"
+	"os"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/abrander/pnmap/capture"
+)
+
+// openCapture opens a capture file or named pipe. "-" is standard input.
+func openCapture(path string) (io.ReadCloser, error) {
+	if path == "-" {
+		return io.NopCloser(os.Stdin), nil
+	}
+
+	return os.Open(path)
+}
+
+// frame is a captured ethernet frame waiting to be dissected.
+type frame struct {
+	data []byte
+	ci   gopacket.CaptureInfo
+}
+
+// readCapture reads ethernet frames from a pcap or pcapng stream at path
+// and passes the ones pnmap is interested in to out as they arrive. The
+// stream may be a pipe that never ends. It returns nil at the end of the
+// stream.
+func readCapture(path string, out func(frame)) error {
+	f, err := openCapture(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	reader, err := capture.NewReader(f)
+	if err != nil {
+		return err
+	}
+
+	vm := newFilterVM()
+
+	for {
+		data, ci, linkType, err := reader.ReadFrame()
+		if err == io.EOF {
+			return nil
+		}
+
+		if err != nil {
+			return err
+		}
+
+		if linkType != layers.LinkTypeEthernet {
+			continue
+		}
+
+		n, err := vm.Run(data)
+		if err != nil || n == 0 {
+			continue
+		}
+
+		// Interface numbers in a capture are not interfaces on this host.
+		// The name is kept in the ancillary data.
+		ci.InterfaceIndex = 0
+
+		out(frame{data: data, ci: ci})
+	}
+}
+
+// listenInterfaces returns the interfaces listened on, which is none when
+// reading from capture streams.
+func listenInterfaces() []string {
+	if len(sources) > 0 {
+		return nil
+	}
+
+	return *interfaces
+}
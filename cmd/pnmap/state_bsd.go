@@ -0,0 +1,18 @@
+//go:build darwin || dragonfly || freebsd || netbsd || openbsd
+
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func getStateFile() string {
+	homedir, _ := os.UserHomeDir()
+	return fmt.Sprintf("%s/.pnmap/state.json", homedir)
+}
+
+// networkIdentity is not implemented on BSD.
+func networkIdentity() (string, string) {
+	return "", ""
+}
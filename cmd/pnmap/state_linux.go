@@ -11,17 +11,28 @@ import (
 
 func getStateFile() string {
 	homedir, _ := os.UserHomeDir()
-	gateways := findGateways()
+	gateway, mac := networkIdentity()
 
-	if len(gateways) == 1 {
-		mac := findMacFromIPInArpTable(gateways[0])
-		return fmt.Sprintf("%s/.pnmap/state-%s-%s.json", homedir, mac, gateways[0])
+	if gateway != "" {
+		return fmt.Sprintf("%s/.pnmap/state-%s-%s.json", homedir, mac, gateway)
 	}
 
 	// If we have multiple - or zero - gateways, we fall-back to a generic state file.
 	return fmt.Sprintf("%s/.pnmap/state.json", homedir)
 }
 
+// networkIdentity returns the default gateway and its MAC address if the
+// host has exactly one default gateway.
+func networkIdentity() (string, string) {
+	gateways := findGateways()
+
+	if len(gateways) == 1 {
+		return gateways[0], findMacFromIPInArpTable(gateways[0])
+	}
+
+	return "", ""
+}
+
 // find gateways
 func findGateways() []string {
 	file, err := os.Open("/proc/net/route")
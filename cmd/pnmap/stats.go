@@ -0,0 +1,132 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// interfaceStats counts the packets of a listener for the metrics and the
+// user interface.
+type interfaceStats struct {
+	name string
+
+	// state is set by the supervisor, like "listening" or "down".
+	state atomic.Value
+
+	received   atomic.Uint64
+	dropped    atomic.Uint64
+	overflowed atomic.Uint64
+
+	receivedCounter   prometheus.Counter
+	droppedCounter    prometheus.Counter
+	overflowedCounter prometheus.Counter
+	listeningGauge    prometheus.Gauge
+}
+
+var listeners struct {
+	sync.Mutex
+	stats []*interfaceStats
+}
+
+// newInterfaceStats returns the statistics for the interface name,
+// creating them the first time.
+func newInterfaceStats(name string) *interfaceStats {
+	listeners.Lock()
+	defer listeners.Unlock()
+
+	for _, s := range listeners.stats {
+		if s.name == name {
+			return s
+		}
+	}
+
+	s := &interfaceStats{
+		name:              name,
+		receivedCounter:   sensorMetrics.PacketsReceived(name),
+		droppedCounter:    sensorMetrics.PacketsDropped(name),
+		overflowedCounter: sensorMetrics.PacketsOverflowed(name),
+		listeningGauge:    sensorMetrics.InterfaceListening(name),
+	}
+
+	s.state.Store("")
+
+	listeners.stats = append(listeners.stats, s)
+
+	return s
+}
+
+// forgetInterfaceStats removes the interface name from the status line.
+func forgetInterfaceStats(name string) {
+	listeners.Lock()
+	defer listeners.Unlock()
+
+	for i, s := range listeners.stats {
+		if s.name == name {
+			listeners.stats = append(listeners.stats[:i], listeners.stats[i+1:]...)
+
+			return
+		}
+	}
+}
+
+// SetState sets the state of the listener shown in the status line.
+func (s *interfaceStats) SetState(state string) {
+	s.state.Store(state)
+
+	if state == "listening" {
+		s.listeningGauge.Set(1)
+	} else {
+		s.listeningGauge.Set(0)
+	}
+}
+
+// Received counts a received packet.
+func (s *interfaceStats) Received() {
+	s.received.Add(1)
+	s.receivedCounter.Inc()
+}
+
+// Dropped counts packets dropped by the kernel.
+func (s *interfaceStats) Dropped(n uint64) {
+	s.dropped.Add(n)
+	s.droppedCounter.Add(float64(n))
+}
+
+// Overflowed counts a packet dropped because the dissection queue was full.
+func (s *interfaceStats) Overflowed() {
+	s.overflowed.Add(1)
+	s.overflowedCounter.Inc()
+}
+
+// deliver queues f for dissection without blocking the capture.
+func (s *interfaceStats) deliver(p *pipeline, f frame) {
+	s.Received()
+
+	if !p.Offer(f) {
+		s.Overflowed()
+	}
+}
+
+// listenerStatus returns a line describing the state of each interface and
+// the packets received and dropped on it.
+func listenerStatus() string {
+	listeners.Lock()
+	defer listeners.Unlock()
+
+	parts := make([]string, 0, len(listeners.stats))
+
+	for _, s := range listeners.stats {
+		name := s.name
+		if state := s.state.Load().(string); state != "" {
+			name += " " + state
+		}
+
+		parts = append(parts, fmt.Sprintf("%s: %d received, %d dropped, %d overflowed", name, s.received.Load(), s.dropped.Load(), s.overflowed.Load()))
+	}
+
+	return strings.Join(parts, "  ")
+}
@@ -0,0 +1,295 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"slices"
+	"time"
+)
+
+const (
+	// minBackoff and maxBackoff bound the delay before restarting a failed
+	// listener.
+	minBackoff = time.Second
+	maxBackoff = time.Minute
+
+	// stableAfter is how long a listener must run before a failure is no
+	// longer counted as a repeated failure.
+	stableAfter = time.Minute
+)
+
+// supervisor keeps a listener running on every wanted interface that is up.
+// Listeners are started and stopped as interfaces appear, disappear or
+// change state, and restarted with backoff if they fail.
+type supervisor struct {
+	out    *pipeline
+	logger *slog.Logger
+
+	// names are the interfaces to listen on. If empty, all ethernet
+	// interfaces except loopback are listened on.
+	names []string
+
+	listeners map[string]*supervised
+	changed   chan struct{}
+	exited    chan exit
+}
+
+// supervised is the state of the listener on one interface.
+type supervised struct {
+	stats *interfaceStats
+	state string
+
+	// run identifies the current listener. cancel stops it, and is nil if
+	// no listener is running.
+	run     int
+	cancel  context.CancelFunc
+	started time.Time
+
+	failures int
+	retryAt  time.Time
+}
+
+// exit is sent when a listener returns.
+type exit struct {
+	name string
+	run  int
+	err  error
+}
+
+func newSupervisor(names []string, out *pipeline, logger *slog.Logger) *supervisor {
+	return &supervisor{
+		out:       out,
+		logger:    logger,
+		names:     names,
+		listeners: make(map[string]*supervised),
+		changed:   make(chan struct{}, 1),
+		exited:    make(chan exit),
+	}
+}
+
+// notify signals c without blocking. A pending signal is enough.
+func notify(c chan<- struct{}) {
+	select {
+	case c <- struct{}{}:
+	default:
+	}
+}
+
+// closeWhenDone closes c when ctx is done, which interrupts a blocked read.
+// The returned function closes c if that did not already happen.
+func closeWhenDone(ctx context.Context, c io.Closer) func() {
+	stop := context.AfterFunc(ctx, func() {
+		c.Close()
+	})
+
+	return func() {
+		if stop() {
+			c.Close()
+		}
+	}
+}
+
+// Run supervises listeners until ctx is done.
+func (s *supervisor) Run(ctx context.Context) {
+	go func() {
+		err := watchLinks(ctx, s.changed)
+		if err != nil {
+			s.logger.Warn("watching interfaces failed, checking every 5 seconds", "error", err)
+
+			ticker := time.NewTicker(5 * time.Second)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+
+				case <-ticker.C:
+					notify(s.changed)
+				}
+			}
+		}
+	}()
+
+	s.reconcile(ctx)
+
+	for {
+		select {
+		case <-ctx.Done():
+			for name := range s.listeners {
+				s.stop(name)
+			}
+
+			return
+
+		case <-s.changed:
+			s.reconcile(ctx)
+
+		case e := <-s.exited:
+			s.exit(e)
+			s.reconcile(ctx)
+		}
+	}
+}
+
+// wanted returns true if the interface should be listened on.
+func (s *supervisor) wanted(intf net.Interface) bool {
+	if len(s.names) > 0 {
+		return slices.Contains(s.names, intf.Name)
+	}
+
+	// Tunnels and the like do not carry ethernet frames.
+	return intf.Flags&net.FlagLoopback == 0 && len(intf.HardwareAddr) == 6
+}
+
+// reconcile starts and stops listeners to match the interfaces of the host.
+func (s *supervisor) reconcile(ctx context.Context) {
+	intfs, err := net.Interfaces()
+	if err != nil {
+		s.logger.Warn("listing interfaces failed", "error", err)
+
+		return
+	}
+
+	present := make(map[string]net.Interface)
+
+	for _, intf := range intfs {
+		if s.wanted(intf) {
+			present[intf.Name] = intf
+		}
+	}
+
+	// Interfaces given by name are shown even if they are missing.
+	for _, name := range s.names {
+		if _, found := present[name]; !found {
+			s.stop(name)
+			s.setState(s.get(name), name, "not found", slog.LevelWarn)
+		}
+	}
+
+	for name := range s.listeners {
+		if _, found := present[name]; !found && !slices.Contains(s.names, name) {
+			s.stop(name)
+			s.logger.Info("interface removed", "interface", name)
+
+			delete(s.listeners, name)
+			forgetInterfaceStats(name)
+		}
+	}
+
+	now := time.Now()
+
+	for name, intf := range present {
+		l := s.get(name)
+
+		if intf.Flags&net.FlagUp == 0 || intf.Flags&net.FlagRunning == 0 {
+			s.stop(name)
+			s.setState(l, name, "down", slog.LevelInfo)
+
+			// A link coming back up is a fresh start.
+			l.failures = 0
+			l.retryAt = time.Time{}
+
+			continue
+		}
+
+		if l.cancel == nil && !now.Before(l.retryAt) {
+			s.start(ctx, name)
+		}
+	}
+}
+
+// get returns the listener state of name, creating it if needed.
+func (s *supervisor) get(name string) *supervised {
+	l, found := s.listeners[name]
+	if !found {
+		l = &supervised{stats: newInterfaceStats(name)}
+		s.listeners[name] = l
+	}
+
+	return l
+}
+
+// setState updates the state of l and logs changes at level.
+func (s *supervisor) setState(l *supervised, name string, state string, level slog.Level, args ...any) {
+	if l.state == state {
+		return
+	}
+
+	l.state = state
+	l.stats.SetState(state)
+
+	s.logger.Log(context.Background(), level, "interface "+state, append([]any{"interface", name}, args...)...)
+}
+
+func (s *supervisor) start(ctx context.Context, name string) {
+	l := s.get(name)
+
+	runCtx, cancel := context.WithCancel(ctx)
+
+	l.run++
+	l.cancel = cancel
+	l.started = time.Now()
+
+	s.setState(l, name, "listening", slog.LevelInfo)
+
+	go func(run int) {
+		err := listen(runCtx, name, s.out, s.logger)
+
+		// Run no longer receives once ctx is done.
+		select {
+		case s.exited <- exit{name: name, run: run, err: err}:
+		case <-ctx.Done():
+		}
+	}(l.run)
+}
+
+// stop stops the listener on name if it is running.
+func (s *supervisor) stop(name string) {
+	l, found := s.listeners[name]
+	if !found || l.cancel == nil {
+		return
+	}
+
+	l.cancel()
+	l.cancel = nil
+}
+
+// exit handles a listener returning. Failed listeners are retried with
+// exponential backoff.
+func (s *supervisor) exit(e exit) {
+	l, found := s.listeners[e.name]
+	if !found || e.run != l.run || l.cancel == nil {
+		// Stopped on purpose.
+		return
+	}
+
+	l.cancel()
+	l.cancel = nil
+
+	if e.err == nil {
+		e.err = fmt.Errorf("listener stopped")
+	}
+
+	if time.Since(l.started) > stableAfter {
+		l.failures = 0
+	}
+
+	l.failures++
+
+	delay := min(minBackoff<<(l.failures-1), maxBackoff)
+	if l.failures > 16 {
+		delay = maxBackoff
+	}
+
+	l.retryAt = time.Now().Add(delay)
+
+	s.setState(l, e.name, "failed", slog.LevelWarn, "error", e.err, "retry", delay)
+
+	time.AfterFunc(delay, func() {
+		notify(s.changed)
+	})
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+
+	"github.com/abrander/pnmap/intel"
+	"github.com/abrander/pnmap/syslog"
+)
+
+var (
+	syslogTarget   string
+	syslogFormat   string
+	syslogFacility string
+	syslogCA       string
+)
+
+// startSyslog starts sending events from i to the syslog server given by
+// --syslog, if any.
+func startSyslog(i *intel.Intel, logger *slog.Logger) error {
+	if syslogTarget == "" {
+		return nil
+	}
+
+	facility, err := syslog.ParseFacility(syslogFacility)
+	if err != nil {
+		return err
+	}
+
+	var tlsConfig *tls.Config
+	if syslogCA != "" {
+		pem, err := os.ReadFile(syslogCA)
+		if err != nil {
+			return err
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("%s: no certificates found", syslogCA)
+		}
+
+		tlsConfig = &tls.Config{RootCAs: pool}
+	}
+
+	w, err := syslog.Dial(syslogTarget, tlsConfig)
+	if err != nil {
+		return err
+	}
+
+	w.Facility = facility
+
+	sink, err := syslog.NewSink(w, syslogFormat)
+	if err != nil {
+		return err
+	}
+
+	sink.OnError = func(err error) {
+		logger.Error("sending to syslog failed", "target", syslogTarget, "error", err)
+	}
+
+	go sink.Run(i.Subscribe(1000))
+
+	return nil
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+
+	"github.com/abrander/pnmap/capture"
+)
+
+// pcapWriter appends packets to a pcap or pcapng file. It can be reopened to
+// support log rotation.
+type pcapWriter struct {
+	sync.Mutex
+
+	path   string
+	format capture.Format
+	file   *os.File
+	writer *pcapgo.Writer
+
+	// ng is used instead of writer for pcapng. Interface description blocks
+	// are written the first time an interface is seen in each section.
+	ng         *capture.NgWriter
+	interfaces map[string]int
+}
+
+// newPcapWriter returns a writer appending to path. format is "pcap",
+// "pcapng" or empty to choose from the file extension.
+func newPcapWriter(path string, format string) (*pcapWriter, error) {
+	p := &pcapWriter{path: path}
+
+	switch format {
+	case "":
+		if strings.EqualFold(filepath.Ext(path), ".pcapng") {
+			p.format = capture.Pcapng
+		}
+	case "pcap":
+		p.format = capture.Pcap
+	case "pcapng":
+		p.format = capture.Pcapng
+	default:
+		return nil, fmt.Errorf("unknown capture format '%s'", format)
+	}
+
+	err := p.open()
+	if err != nil {
+		return nil, err
+	}
+
+	return p, nil
+}
+
+func (p *pcapWriter) open() error {
+	f, err := os.OpenFile(p.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	if p.format == capture.Pcapng {
+		// A pcapng file can hold more than one section, so appending a
+		// new section header is fine.
+		ng, err := capture.NewNgWriter(f, "pnmap")
+		if err == nil {
+			err = ng.Flush()
+		}
+		if err != nil {
+			f.Close()
+
+			return err
+		}
+
+		p.file = f
+		p.ng = ng
+		p.interfaces = make(map[string]int)
+
+		return nil
+	}
+
+	w := pcapgo.NewWriter(f)
+
+	pos, _ := f.Seek(0, 2)
+	if pos == 0 {
+		err = w.WriteFileHeader(65536, layers.LinkTypeEthernet)
+		if err != nil {
+			f.Close()
+
+			return err
+		}
+	}
+
+	p.file = f
+	p.writer = w
+
+	return nil
+}
+
+// interfaceName returns the name of the interface a packet was captured
+// on, or "" if unknown.
+func interfaceName(ci gopacket.CaptureInfo) string {
+	for _, a := range ci.AncillaryData {
+		if name, ok := a.(capture.InterfaceName); ok {
+			return string(name)
+		}
+	}
+
+	if ci.InterfaceIndex == 0 {
+		return ""
+	}
+
+	intf, err := net.InterfaceByIndex(ci.InterfaceIndex)
+	if err != nil {
+		return fmt.Sprintf("if%d", ci.InterfaceIndex)
+	}
+
+	return intf.Name
+}
+
+// WritePacket writes a packet to the file. In pcapng files the packet is
+// commented with the interface it was captured on.
+func (p *pcapWriter) WritePacket(ci gopacket.CaptureInfo, data []byte) error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.writer == nil && p.ng == nil {
+		return os.ErrClosed
+	}
+
+	if p.ng == nil {
+		return p.writer.WritePacket(ci, data)
+	}
+
+	name := interfaceName(ci)
+
+	id, found := p.interfaces[name]
+	if !found {
+		var err error
+
+		id, err = p.ng.AddInterface(name, layers.LinkTypeEthernet)
+		if err != nil {
+			return err
+		}
+
+		p.interfaces[name] = id
+	}
+
+	var comment string
+	if name != "" {
+		comment = "captured on " + name
+	}
+
+	err := p.ng.WritePacket(id, ci, data, comment)
+	if err != nil {
+		return err
+	}
+
+	return p.ng.Flush()
+}
+
+// Reopen closes and reopens the file.
+func (p *pcapWriter) Reopen() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.file != nil {
+		p.file.Close()
+		p.file = nil
+		p.writer = nil
+		p.ng = nil
+	}
+
+	return p.open()
+}
+
+// Close closes the file.
+func (p *pcapWriter) Close() error {
+	p.Lock()
+	defer p.Unlock()
+
+	if p.file == nil {
+		return nil
+	}
+
+	err := p.file.Close()
+	p.file = nil
+	p.writer = nil
+	p.ng = nil
+
+	return err
+}
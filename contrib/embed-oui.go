@@ -15,7 +15,7 @@ import (
 )
 
 var ouiTemplate = template.Must(template.New("").Parse(`// Code generated by go generate; DO NOT EDIT.
-package main
+package oui
 
 var ouiToVendor = map[string]string{
 {{ range $key, $value := . }}	"{{ $key }}": "{{ $value }}",
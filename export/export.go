@@ -0,0 +1,243 @@
+// Package export writes stations in formats suitable for other tools.
+package export
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/pnmap/intel"
+	"github.com/abrander/pnmap/oui"
+)
+
+// Columns lists the available columns in their default order.
+var Columns = []string{
+	"mac",
+	"oui",
+	"ips",
+	"hostnames",
+	"useragents",
+	"vendors",
+	"applications",
+	"sensors",
+	"firstseen",
+	"lastseen",
+	"packets",
+}
+
+// Formats lists the supported output formats.
+var Formats = []string{"csv", "json", "ndjson", "markdown"}
+
+// value returns the value of column for nic. Lists are returned as
+// []string, counts as int and everything else as string.
+func value(nic *intel.NIC, column string) interface{} {
+	switch column {
+	case "mac":
+		return nic.MAC
+	case "oui":
+		return oui.Vendor(nic.MAC)
+	case "ips":
+		return nic.IPs.Values()
+	case "hostnames":
+		return nic.Hostnames.Values()
+	case "useragents":
+		return nic.UserAgents.Values()
+	case "vendors":
+		return nic.Vendor.Values()
+	case "applications":
+		return nic.Applications.Values()
+	case "sensors":
+		return nic.Sensors.Values()
+	case "firstseen":
+		return nic.FirstSeen.UTC().Format(time.RFC3339)
+	case "lastseen":
+		return nic.LastSeen.UTC().Format(time.RFC3339)
+	case "packets":
+		return nic.Seen
+	}
+
+	return nil
+}
+
+// text returns the value of column for nic as a string, with lists joined
+// by sep.
+func text(nic *intel.NIC, column string, sep string) string {
+	switch v := value(nic, column).(type) {
+	case []string:
+		return strings.Join(v, sep)
+	case int:
+		return strconv.Itoa(v)
+	case string:
+		return v
+	}
+
+	return ""
+}
+
+// record is a station as a JSON object with the keys in column order.
+type record struct {
+	nic     *intel.NIC
+	columns []string
+}
+
+func (r record) MarshalJSON() ([]byte, error) {
+	buf := bytes.NewBufferString("{")
+
+	for i, c := range r.columns {
+		v := value(r.nic, c)
+
+		// Empty lists are exported as empty arrays, not null.
+		if l, ok := v.([]string); ok && l == nil {
+			v = []string{}
+		}
+
+		key, _ := json.Marshal(c)
+
+		val, err := json.Marshal(v)
+		if err != nil {
+			return nil, err
+		}
+
+		if i > 0 {
+			buf.WriteByte(',')
+		}
+
+		buf.Write(key)
+		buf.WriteByte(':')
+		buf.Write(val)
+	}
+
+	buf.WriteByte('}')
+
+	return buf.Bytes(), nil
+}
+
+// Sorted returns the stations of nics sorted by MAC address.
+func Sorted(nics intel.NICCollection) []*intel.NIC {
+	list := make([]*intel.NIC, 0, len(nics))
+
+	for _, nic := range nics {
+		list = append(list, nic)
+	}
+
+	sort.Slice(list, func(a, b int) bool {
+		return list[a].MAC < list[b].MAC
+	})
+
+	return list
+}
+
+// Write writes nics to w in format with the given columns. If columns is
+// empty, all columns are written.
+func Write(w io.Writer, format string, columns []string, nics []*intel.NIC) error {
+	if len(columns) == 0 {
+		columns = Columns
+	}
+
+	for _, c := range columns {
+		if value(&intel.NIC{}, c) == nil {
+			return fmt.Errorf("unknown column '%s'", c)
+		}
+	}
+
+	switch format {
+	case "csv":
+		return writeCSV(w, columns, nics)
+	case "json":
+		return writeJSON(w, columns, nics)
+	case "ndjson":
+		return writeNDJSON(w, columns, nics)
+	case "markdown":
+		return writeMarkdown(w, columns, nics)
+	}
+
+	return fmt.Errorf("unknown format '%s'", format)
+}
+
+func writeCSV(w io.Writer, columns []string, nics []*intel.NIC) error {
+	c := csv.NewWriter(w)
+
+	err := c.Write(columns)
+	if err != nil {
+		return err
+	}
+
+	row := make([]string, len(columns))
+
+	for _, nic := range nics {
+		for i, column := range columns {
+			row[i] = text(nic, column, ";")
+		}
+
+		err = c.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	c.Flush()
+
+	return c.Error()
+}
+
+func writeJSON(w io.Writer, columns []string, nics []*intel.NIC) error {
+	records := make([]record, len(nics))
+
+	for i, nic := range nics {
+		records[i] = record{nic, columns}
+	}
+
+	e := json.NewEncoder(w)
+	e.SetIndent("", "  ")
+
+	return e.Encode(records)
+}
+
+func writeNDJSON(w io.Writer, columns []string, nics []*intel.NIC) error {
+	e := json.NewEncoder(w)
+
+	for _, nic := range nics {
+		err := e.Encode(record{nic, columns})
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// markdownEscaper escapes characters with special meaning in table cells.
+var markdownEscaper = strings.NewReplacer("|", "\\|", "\n", " ", "\r", "")
+
+func writeMarkdown(w io.Writer, columns []string, nics []*intel.NIC) error {
+	_, err := fmt.Fprintf(w, "| %s |\n", strings.Join(columns, " | "))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(w, "|%s\n", strings.Repeat(" --- |", len(columns)))
+	if err != nil {
+		return err
+	}
+
+	cells := make([]string, len(columns))
+
+	for _, nic := range nics {
+		for i, column := range columns {
+			cells[i] = markdownEscaper.Replace(text(nic, column, ", "))
+		}
+
+		_, err = fmt.Fprintf(w, "| %s |\n", strings.Join(cells, " | "))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,288 @@
+// Package history stores stations and every observation made about them in
+// an embedded database.
+package history
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+var (
+	bucketNICs         = []byte("nics")
+	bucketObservations = []byte("observations")
+	bucketIPs          = []byte("ips")
+	bucketHostnames    = []byte("hostnames")
+)
+
+// Record is a single observation as stored in the history.
+type Record struct {
+	Type   string    `json:"Type"`
+	MAC    string    `json:"MAC"`
+	Value  string    `json:"Value"`
+	Source string    `json:"Source"`
+	Time   time.Time `json:"Time"`
+}
+
+// DB is a history database.
+type DB struct {
+	db *bolt.DB
+}
+
+// Open opens or creates the database at path.
+func Open(path string) (*DB, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketNICs, bucketObservations, bucketIPs, bucketHostnames} {
+			_, err := tx.CreateBucketIfNotExists(name)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	return &DB{db: db}, nil
+}
+
+// OpenReadOnly opens the existing database at path for reading. Unlike
+// Open, it fails if there is no database at path.
+func OpenReadOnly(path string) (*DB, error) {
+	db, err := bolt.Open(path, 0600, &bolt.Options{Timeout: time.Second, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+
+	err = db.View(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{bucketNICs, bucketObservations, bucketIPs, bucketHostnames} {
+			if tx.Bucket(name) == nil {
+				return fmt.Errorf("not a history database, %s is missing", name)
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		db.Close()
+
+		return nil, err
+	}
+
+	return &DB{db: db}, nil
+}
+
+// Close closes the database.
+func (d *DB) Close() error {
+	return d.db.Close()
+}
+
+// indexKey returns the key used to index mac by value.
+func indexKey(value string, mac string) []byte {
+	return append(append([]byte(value), 0), mac...)
+}
+
+// observationKey returns a key sorting observations by station and time.
+// Observations without a time, such as those migrated from old state files,
+// sort first.
+func observationKey(mac string, t time.Time, seq uint64) []byte {
+	// UnixNano is undefined for the zero time.
+	var nanos uint64
+	if !t.IsZero() {
+		nanos = uint64(t.UnixNano())
+	}
+
+	key := append([]byte(mac), 0)
+	key = binary.BigEndian.AppendUint64(key, nanos)
+	key = binary.BigEndian.AppendUint64(key, seq)
+
+	return key
+}
+
+// putNIC stores nic and indexes its IPs and hostnames.
+func putNIC(tx *bolt.Tx, nic *intel.NIC) error {
+	data, err := json.Marshal(nic)
+	if err != nil {
+		return err
+	}
+
+	err = tx.Bucket(bucketNICs).Put([]byte(nic.MAC), data)
+	if err != nil {
+		return err
+	}
+
+	for _, ip := range nic.IPs.Values() {
+		err = tx.Bucket(bucketIPs).Put(indexKey(ip, nic.MAC), nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, hostname := range nic.Hostnames.Values() {
+		err = tx.Bucket(bucketHostnames).Put(indexKey(hostname, nic.MAC), nil)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// putRecord appends r to the observations.
+func putRecord(tx *bolt.Tx, r Record) error {
+	b := tx.Bucket(bucketObservations)
+
+	seq, err := b.NextSequence()
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	return b.Put(observationKey(r.MAC, r.Time, seq), data)
+}
+
+// PutNICs stores nics, replacing existing records for the same stations.
+func (d *DB) PutNICs(nics intel.NICCollection) error {
+	return d.db.Update(func(tx *bolt.Tx) error {
+		for _, nic := range nics {
+			err := putNIC(tx, nic)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// NICs returns all stations in the database.
+func (d *DB) NICs() (intel.NICCollection, error) {
+	nics := make(intel.NICCollection)
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(bucketNICs).ForEach(func(k, v []byte) error {
+			nic := &intel.NIC{}
+
+			err := json.Unmarshal(v, nic)
+			if err != nil {
+				return err
+			}
+
+			nics[nic.MAC] = nic
+
+			return nil
+		})
+	})
+
+	return nics, err
+}
+
+// NIC returns the station with the given MAC address or nil if it is
+// unknown.
+func (d *DB) NIC(mac string) (*intel.NIC, error) {
+	var nic *intel.NIC
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		var err error
+
+		nic, err = getNIC(tx, mac)
+
+		return err
+	})
+
+	return nic, err
+}
+
+// getNIC returns the stored station with the given MAC address or nil if it
+// is unknown.
+func getNIC(tx *bolt.Tx, mac string) (*intel.NIC, error) {
+	data := tx.Bucket(bucketNICs).Get([]byte(mac))
+	if data == nil {
+		return nil, nil
+	}
+
+	nic := &intel.NIC{}
+
+	err := json.Unmarshal(data, nic)
+	if err != nil {
+		return nil, err
+	}
+
+	return nic, nil
+}
+
+// lookup returns the MAC addresses indexed by value in bucket.
+func (d *DB) lookup(bucket []byte, value string) ([]string, error) {
+	var macs []string
+
+	prefix := append([]byte(value), 0)
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucket).Cursor()
+
+		for k, _ := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, _ = c.Next() {
+			macs = append(macs, string(k[len(prefix):]))
+		}
+
+		return nil
+	})
+
+	return macs, err
+}
+
+// ByIP returns the MAC addresses of stations ever seen using ip.
+func (d *DB) ByIP(ip string) ([]string, error) {
+	return d.lookup(bucketIPs, ip)
+}
+
+// ByHostname returns the MAC addresses of stations ever seen using
+// hostname.
+func (d *DB) ByHostname(hostname string) ([]string, error) {
+	return d.lookup(bucketHostnames, hostname)
+}
+
+// History returns all observations of the station with the given MAC
+// address, oldest first.
+func (d *DB) History(mac string) ([]Record, error) {
+	var records []Record
+
+	prefix := append([]byte(mac), 0)
+
+	err := d.db.View(func(tx *bolt.Tx) error {
+		c := tx.Bucket(bucketObservations).Cursor()
+
+		for k, v := c.Seek(prefix); k != nil && bytes.HasPrefix(k, prefix); k, v = c.Next() {
+			var r Record
+
+			err := json.Unmarshal(v, &r)
+			if err != nil {
+				return err
+			}
+
+			records = append(records, r)
+		}
+
+		return nil
+	})
+
+	return records, err
+}
@@ -0,0 +1,112 @@
+package history
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+func TestHistoryOrder(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "history.db"))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer db.Close()
+
+	const mac = "02:00:00:00:00:01"
+
+	now := time.Now()
+
+	// Written out of order, with one record missing its time.
+	records := []Record{
+		{Type: "IPAdded", MAC: mac, Value: "192.0.2.2", Time: now.Add(time.Minute)},
+		{Type: "IPAdded", MAC: mac, Value: "192.0.2.0"},
+		{Type: "IPAdded", MAC: mac, Value: "192.0.2.1", Time: now},
+		{Type: "IPAdded", MAC: "02:00:00:00:00:02", Value: "192.0.2.3", Time: now},
+	}
+
+	err = db.db.Update(func(tx *bolt.Tx) error {
+		for _, r := range records {
+			err := putRecord(tx, r)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	history, err := db.History(mac)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	var values []string
+	for _, r := range history {
+		values = append(values, r.Value)
+	}
+
+	expected := []string{"192.0.2.0", "192.0.2.1", "192.0.2.2"}
+	if !slices.Equal(values, expected) {
+		t.Errorf("got %v, expected %v", values, expected)
+	}
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "history.db")
+
+	_, err := OpenReadOnly(path)
+	if err == nil {
+		t.Fatal("opened a missing database")
+	}
+
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("opening created %s", path)
+	}
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	err = db.PutNICs(intel.NICCollection{"02:00:00:00:00:01": {MAC: "02:00:00:00:00:01"}})
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	db.Close()
+
+	db, err = OpenReadOnly(path)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer db.Close()
+
+	nics, err := db.NICs()
+	if err != nil || len(nics) != 1 {
+		t.Errorf("got %d stations and %v, expected 1", len(nics), err)
+	}
+
+	// Other bolt databases are not history databases.
+	other, err := bolt.Open(filepath.Join(dir, "other.db"), 0600, nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	other.Close()
+
+	_, err = OpenReadOnly(filepath.Join(dir, "other.db"))
+	if err == nil {
+		t.Error("opened a database without buckets")
+	}
+}
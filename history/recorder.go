@@ -0,0 +1,276 @@
+package history
+
+import (
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+// Recorder writes events from an Intel to a DB. Events are written in
+// batches, and only changed stations are rewritten. If events are lost,
+// the missing facts are recorded from a snapshot of the stations.
+type Recorder struct {
+	db       *DB
+	intel    *intel.Intel
+	sub      *intel.Subscription
+	interval time.Duration
+
+	// OnWrite is called after every batch, if set.
+	OnWrite func(duration time.Duration, err error)
+
+	dropped uint64
+	pending []intel.Event
+	err     error
+
+	stop     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewRecorder returns a Recorder writing events from i to db every interval.
+func NewRecorder(db *DB, i *intel.Intel, interval time.Duration) *Recorder {
+	return &Recorder{
+		db:       db,
+		intel:    i,
+		sub:      i.Subscribe(10000),
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start starts recording in the background.
+func (r *Recorder) Start() {
+	go r.run()
+}
+
+func (r *Recorder) run() {
+	defer close(r.done)
+
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case e := <-r.sub.C:
+			r.pending = append(r.pending, e)
+
+		case <-ticker.C:
+			r.write()
+
+		case <-r.stop:
+			// Drain what is already queued.
+			for {
+				select {
+				case e := <-r.sub.C:
+					r.pending = append(r.pending, e)
+				default:
+					r.write()
+
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *Recorder) write() {
+	dropped := r.sub.Dropped()
+	if len(r.pending) == 0 && dropped == r.dropped {
+		return
+	}
+
+	start := time.Now()
+
+	err := r.db.db.Update(func(tx *bolt.Tx) error {
+		b := newBatch(tx)
+
+		latest := make(map[string]*intel.NIC)
+
+		for _, e := range r.pending {
+			latest[e.MAC] = e.NIC
+
+			if e.Type == intel.NICSeen {
+				continue
+			}
+
+			err := b.put(Record{
+				Type:   e.Type.String(),
+				MAC:    e.MAC,
+				Value:  e.Value,
+				Source: e.Source,
+				Time:   e.Time,
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		// If events were lost, record what is missing from a snapshot.
+		if dropped != r.dropped {
+			latest = r.intel.NICs()
+
+			for _, nic := range latest {
+				for _, rec := range facts(nic) {
+					err := b.put(rec)
+					if err != nil {
+						return err
+					}
+				}
+			}
+		}
+
+		for _, nic := range latest {
+			err := putNIC(tx, nic)
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+
+	if err == nil {
+		r.pending = r.pending[:0]
+		r.dropped = dropped
+	}
+
+	r.err = err
+
+	if r.OnWrite != nil {
+		r.OnWrite(time.Since(start), err)
+	}
+}
+
+// batch writes records in a transaction, skipping facts already recorded.
+// A fact is recorded if the stored station has it, or it was put in the
+// batch. This keeps facts from being recorded twice when events arrive
+// after a snapshot covering them.
+type batch struct {
+	tx       *bolt.Tx
+	stored   map[string]*intel.NIC
+	recorded map[Record]bool
+}
+
+func newBatch(tx *bolt.Tx) *batch {
+	return &batch{
+		tx:       tx,
+		stored:   make(map[string]*intel.NIC),
+		recorded: make(map[Record]bool),
+	}
+}
+
+// known returns true if the fact of r is recorded. Stations must not be
+// written before the batch is done.
+func (b *batch) known(r Record) (bool, error) {
+	key := Record{Type: r.Type, MAC: r.MAC, Value: r.Value}
+	if b.recorded[key] {
+		return true, nil
+	}
+
+	stored, found := b.stored[r.MAC]
+	if !found {
+		var err error
+
+		stored, err = getNIC(b.tx, r.MAC)
+		if err != nil {
+			return false, err
+		}
+
+		b.stored[r.MAC] = stored
+	}
+
+	if stored == nil {
+		return false, nil
+	}
+
+	switch r.Type {
+	case intel.NICDiscovered.String():
+		return true, nil
+
+	case intel.NICStale.String():
+		return false, nil
+	}
+
+	for _, rec := range facts(stored) {
+		if rec.Type == r.Type && rec.Value == r.Value {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// put records r unless its fact is already recorded.
+func (b *batch) put(r Record) error {
+	known, err := b.known(r)
+	if err != nil || known {
+		return err
+	}
+
+	b.recorded[Record{Type: r.Type, MAC: r.MAC, Value: r.Value}] = true
+
+	return putRecord(b.tx, r)
+}
+
+// facts returns records of everything known about nic, as the events first
+// reporting it would have been recorded.
+func facts(nic *intel.NIC) []Record {
+	records := []Record{{
+		Type:   intel.NICDiscovered.String(),
+		MAC:    nic.MAC,
+		Source: "ethernet",
+		Time:   nic.FirstSeen,
+	}}
+
+	for _, f := range []struct {
+		typ          intel.EventType
+		observations intel.Observations
+	}{
+		{intel.IPAdded, nic.IPs},
+		{intel.HostnameAdded, nic.Hostnames},
+		{intel.UserAgentAdded, nic.UserAgents},
+		{intel.VendorAdded, nic.Vendor},
+		{intel.ApplicationAdded, nic.Applications},
+	} {
+		first := make(map[string]intel.Observation)
+
+		for _, o := range f.observations {
+			if prev, found := first[o.Value]; !found || o.FirstSeen.Before(prev.FirstSeen) {
+				first[o.Value] = o
+			}
+		}
+
+		for _, o := range first {
+			records = append(records, Record{
+				Type:   f.typ.String(),
+				MAC:    nic.MAC,
+				Value:  o.Value,
+				Source: o.Source,
+				Time:   o.FirstSeen,
+			})
+		}
+	}
+
+	return records
+}
+
+// Stop writes pending events and stops recording. It returns the error from
+// the last write, if any.
+func (r *Recorder) Stop() error {
+	r.stopOnce.Do(func() {
+		// Record stations seen since their last NICSeen event.
+		r.intel.Flush()
+
+		close(r.stop)
+	})
+
+	<-r.done
+
+	r.sub.Close()
+
+	return r.err
+}
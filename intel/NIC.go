@@ -0,0 +1,124 @@
+package intel
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// NIC contains information about an ethernet station.
+type NIC struct {
+	MAC          string       `json:"MAC"`
+	IPs          Observations `json:"IPs"`
+	Hostnames    Observations `json:"Hostnames"`
+	UserAgents   Observations `json:"UserAgents"`
+	Vendor       Observations `json:"Vendor"`
+	Applications Observations `json:"Applications"`
+
+	// Sensors records the remote sensors and interfaces that have seen the
+	// station, as "sensor/interface".
+	Sensors Observations `json:"Sensors,omitempty"`
+
+	Seen      int       `json:"Seen"`
+	LastSeen  time.Time `json:"LastSeen"`
+	FirstSeen time.Time `json:"FirstSeen"`
+
+	// stale is set when a NICStale event has been emitted for the station.
+	stale bool
+
+	// unpublished counts the packets not yet published in NICSeen events,
+	// per interface.
+	unpublished []unpublished
+}
+
+// unpublished counts packets from a station on an interface.
+type unpublished struct {
+	ifindex int
+	count   int
+	last    time.Time
+}
+
+// NICCollection is a collection of stations indexed by MAC address.
+type NICCollection map[string]*NIC
+
+// Copy returns a deep copy of the collection.
+func (c NICCollection) Copy() NICCollection {
+	cp := make(NICCollection, len(c))
+
+	for mac, nic := range c {
+		cp[mac] = nic.Copy()
+	}
+
+	return cp
+}
+
+func mac(addr []byte) string {
+	var buf [32]byte
+
+	return string(appendMAC(buf[:0], addr))
+}
+
+// appendMAC appends addr formatted like mac to dst.
+func appendMAC(dst []byte, addr []byte) []byte {
+	const digits = "0123456789abcdef"
+
+	for i, b := range addr {
+		if i > 0 {
+			dst = append(dst, ':')
+		}
+
+		dst = append(dst, digits[b>>4], digits[b&0x0f])
+	}
+
+	return dst
+}
+
+func newNIC(addr []byte) *NIC {
+	return &NIC{MAC: mac(addr)}
+}
+
+// UnmarshalJSON decodes a station. Observations from older state files
+// without timestamps inherit the first and last seen timestamps of the
+// station.
+func (n *NIC) UnmarshalJSON(data []byte) error {
+	type plain NIC
+
+	err := json.Unmarshal(data, (*plain)(n))
+	if err != nil {
+		return err
+	}
+
+	for _, o := range []Observations{n.IPs, n.Hostnames, n.UserAgents, n.Vendor, n.Applications, n.Sensors} {
+		for i := range o {
+			if o[i].FirstSeen.IsZero() && o[i].LastSeen.IsZero() {
+				o[i].FirstSeen = n.FirstSeen
+				o[i].LastSeen = n.LastSeen
+			}
+		}
+	}
+
+	return nil
+}
+
+// Copy returns a deep copy of the station.
+func (n *NIC) Copy() *NIC {
+	cp := *n
+
+	cp.IPs = n.IPs.copy()
+	cp.Hostnames = n.Hostnames.copy()
+	cp.UserAgents = n.UserAgents.copy()
+	cp.Vendor = n.Vendor.copy()
+	cp.Applications = n.Applications.copy()
+	cp.Sensors = n.Sensors.copy()
+	cp.unpublished = nil
+
+	return &cp
+}
+
+// String returns the MAC address of the station and its IP addresses.
+func (n *NIC) String() string {
+	if len(n.IPs) == 0 {
+		return n.MAC
+	}
+
+	return n.MAC + " [" + n.IPs.String() + "]"
+}
@@ -0,0 +1,108 @@
+package intel
+
+import (
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	pnlayers "github.com/abrander/pnmap/layers"
+)
+
+// Decoder decodes ethernet frames into preallocated layers with a
+// gopacket.DecodingLayerParser, rather than allocating every layer like
+// gopacket.NewPacket. Frames the Decoder cannot fully decode are handed to
+// gopacket.NewPacket instead, so dissectors see the same layers either way.
+// A Decoder must not be used concurrently.
+type Decoder struct {
+	parser  *gopacket.DecodingLayerParser
+	decoded []gopacket.LayerType
+	layers  []gopacket.Layer
+	byType  map[gopacket.LayerType]gopacket.Layer
+
+	ethernet  layers.Ethernet
+	dot1q     layers.Dot1Q
+	arp       layers.ARP
+	ipv4      layers.IPv4
+	ipv6      layers.IPv6
+	icmpv6    layers.ICMPv6
+	na        layers.ICMPv6NeighborAdvertisement
+	udp       layers.UDP
+	dhcpv4    layers.DHCPv4
+	dhcpv6    layers.DHCPv6
+	dns       layers.DNS
+	mndp      pnlayers.MNDP
+	uDiscover pnlayers.UDiscovery
+	payload   gopacket.Payload
+}
+
+// deadEnds are layer types the Decoder does not decode because no
+// dissector can be reached through them. Frames stopping at any other
+// layer the Decoder does not know are decoded by gopacket.NewPacket.
+var deadEnds = map[gopacket.LayerType]bool{
+	layers.LayerTypeTCP:        true,
+	layers.LayerTypeICMPv4:     true,
+	layers.LayerTypeIGMP:       true,
+	gopacket.LayerTypeFragment: true,
+
+	layers.LayerTypeICMPv6Echo:                   true,
+	layers.LayerTypeICMPv6RouterSolicitation:     true,
+	layers.LayerTypeICMPv6RouterAdvertisement:    true,
+	layers.LayerTypeICMPv6NeighborSolicitation:   true,
+	layers.LayerTypeICMPv6Redirect:               true,
+	layers.LayerTypeMLDv1MulticastListenerQuery:  true,
+	layers.LayerTypeMLDv2MulticastListenerQuery:  true,
+	layers.LayerTypeMLDv1MulticastListenerReport: true,
+	layers.LayerTypeMLDv1MulticastListenerDone:   true,
+	layers.LayerTypeMLDv2MulticastListenerReport: true,
+}
+
+// NewDecoder returns a new Decoder.
+func NewDecoder() *Decoder {
+	d := &Decoder{}
+
+	decoding := []gopacket.DecodingLayer{
+		&d.ethernet,
+		&d.dot1q,
+		&d.arp,
+		&d.ipv4,
+		&d.ipv6,
+		&d.icmpv6,
+		&d.na,
+		&d.udp,
+		&d.dhcpv4,
+		&d.dhcpv6,
+		&d.dns,
+		&d.mndp,
+		&d.uDiscover,
+		&d.payload,
+	}
+
+	d.parser = gopacket.NewDecodingLayerParser(layers.LayerTypeEthernet, decoding...)
+	d.byType = make(map[gopacket.LayerType]gopacket.Layer, len(decoding))
+
+	for _, l := range decoding {
+		layer := l.(gopacket.Layer)
+		d.byType[layer.LayerType()] = layer
+	}
+
+	return d
+}
+
+// decode decodes data. It returns false if the frame must be decoded by
+// gopacket.NewPacket. The layers are only valid until the next call.
+func (d *Decoder) decode(data []byte) ([]gopacket.Layer, bool) {
+	err := d.parser.DecodeLayers(data, &d.decoded)
+
+	// Other errors are malformed layers. Like gopacket.NewPacket, the
+	// layers decoded before are kept.
+	unsupported, ok := err.(gopacket.UnsupportedLayerType)
+	if ok && !deadEnds[gopacket.LayerType(unsupported)] {
+		return nil, false
+	}
+
+	d.layers = d.layers[:0]
+	for _, t := range d.decoded {
+		d.layers = append(d.layers, d.byType[t])
+	}
+
+	return d.layers, true
+}
@@ -0,0 +1,182 @@
+package intel
+
+import (
+	"fmt"
+	"net"
+	"sort"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+)
+
+// Dissector priorities. Dissectors with a lower priority run first, so that
+// higher layers can rely on facts learned from lower layers.
+const (
+	PriorityLink        = 100
+	PriorityNetwork     = 200
+	PriorityApplication = 300
+)
+
+// dissectFunc extracts information from a layer sent by source into the
+// shard of source. It returns true if the layer was recognized.
+type dissectFunc func(s *shard, source net.HardwareAddr, layer gopacket.Layer) (bool, error)
+
+type dissector struct {
+	name      string
+	layerType gopacket.LayerType
+	priority  int
+	ports     []layers.UDPPort
+	fun       dissectFunc
+
+	enabled    int32
+	seen       uint64
+	recognized uint64
+	errors     uint64
+	nanos      int64
+}
+
+// DissectorStats is a snapshot of the counters of a dissector.
+type DissectorStats struct {
+	Name       string
+	Layer      string
+	Priority   int
+	Enabled    bool
+	Seen       uint64
+	Recognized uint64
+	Errors     uint64
+	Time       time.Duration
+}
+
+func (d *dissector) stats() DissectorStats {
+	return DissectorStats{
+		Name:       d.name,
+		Layer:      d.layerType.String(),
+		Priority:   d.priority,
+		Enabled:    atomic.LoadInt32(&d.enabled) == 1,
+		Seen:       atomic.LoadUint64(&d.seen),
+		Recognized: atomic.LoadUint64(&d.recognized),
+		Errors:     atomic.LoadUint64(&d.errors),
+		Time:       time.Duration(atomic.LoadInt64(&d.nanos)),
+	}
+}
+
+// matches returns true if the dissector should see layer.
+func (d *dissector) matches(layer gopacket.Layer) bool {
+	if len(d.ports) == 0 {
+		return true
+	}
+
+	udp, ok := layer.(*layers.UDP)
+	if !ok {
+		return false
+	}
+
+	for _, p := range d.ports {
+		if udp.DstPort == p {
+			return true
+		}
+	}
+
+	return false
+}
+
+// mux is an ordered list of dissectors. More than one dissector can handle
+// the same layer type.
+type mux struct {
+	dissectors []*dissector
+}
+
+func newMux() *mux {
+	return &mux{}
+}
+
+func (m *mux) add(name string, layerType gopacket.LayerType, priority int, fun dissectFunc, ports ...layers.UDPPort) {
+	m.dissectors = append(m.dissectors, &dissector{
+		name:      name,
+		layerType: layerType,
+		priority:  priority,
+		ports:     ports,
+		fun:       fun,
+		enabled:   1,
+	})
+
+	// Stable, to keep registration order for equal priorities.
+	sort.SliceStable(m.dissectors, func(a, b int) bool {
+		return m.dissectors[a].priority < m.dissectors[b].priority
+	})
+}
+
+func (m *mux) find(name string) *dissector {
+	for _, d := range m.dissectors {
+		if d.name == name {
+			return d
+		}
+	}
+
+	return nil
+}
+
+func (m *mux) enable(name string, enabled bool) error {
+	d := m.find(name)
+	if d == nil {
+		return fmt.Errorf("unknown dissector '%s'", name)
+	}
+
+	var v int32
+	if enabled {
+		v = 1
+	}
+
+	atomic.StoreInt32(&d.enabled, v)
+
+	return nil
+}
+
+func (m *mux) stats() []DissectorStats {
+	stats := make([]DissectorStats, len(m.dissectors))
+
+	for i, d := range m.dissectors {
+		stats[i] = d.stats()
+	}
+
+	return stats
+}
+
+// process runs the enabled dissectors on the layers of a packet from
+// source. The lock of s must be held.
+func (m *mux) process(s *shard, source net.HardwareAddr, packetLayers []gopacket.Layer) bool {
+	recognized := false
+	for _, d := range m.dissectors {
+		if atomic.LoadInt32(&d.enabled) == 0 {
+			continue
+		}
+
+		for _, l := range packetLayers {
+			if l.LayerType() != d.layerType || !d.matches(l) {
+				continue
+			}
+
+			atomic.AddUint64(&d.seen, 1)
+
+			start := time.Now()
+			r, err := d.fun(s, source, l)
+			atomic.AddInt64(&d.nanos, int64(time.Since(start)))
+
+			if err != nil {
+				atomic.AddUint64(&d.errors, 1)
+			}
+
+			if r {
+				atomic.AddUint64(&d.recognized, 1)
+				recognized = true
+			}
+
+			// Only the outermost matching layer is dissected.
+			break
+		}
+	}
+
+	return recognized
+}
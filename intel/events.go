@@ -0,0 +1,197 @@
+package intel
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// EventType is the type of a change event.
+type EventType int
+
+const (
+	// NICDiscovered is emitted when a station is seen for the first time.
+	NICDiscovered EventType = iota
+
+	// NICSeen is emitted for stations sending packets, at most once per
+	// station and interface every interval set by WithSeenInterval. Count
+	// is the number of packets since the last NICSeen event.
+	NICSeen
+
+	// NICStale is emitted once when a station has not been seen for the
+	// duration set by WithStaleAfter.
+	NICStale
+
+	// IPAdded is emitted when a new IP address is observed for a station.
+	IPAdded
+
+	// HostnameAdded is emitted when a new hostname is observed for a station.
+	HostnameAdded
+
+	// UserAgentAdded is emitted when a new user agent is observed for a
+	// station.
+	UserAgentAdded
+
+	// VendorAdded is emitted when a new vendor is observed for a station.
+	VendorAdded
+
+	// ApplicationAdded is emitted when a new application is observed for a
+	// station.
+	ApplicationAdded
+)
+
+var eventNames = map[EventType]string{
+	NICDiscovered:    "NICDiscovered",
+	NICSeen:          "NICSeen",
+	NICStale:         "NICStale",
+	IPAdded:          "IPAdded",
+	HostnameAdded:    "HostnameAdded",
+	UserAgentAdded:   "UserAgentAdded",
+	VendorAdded:      "VendorAdded",
+	ApplicationAdded: "ApplicationAdded",
+}
+
+func (t EventType) String() string {
+	name, found := eventNames[t]
+	if !found {
+		return "unknown"
+	}
+
+	return name
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (t EventType) MarshalText() ([]byte, error) {
+	return []byte(t.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler. It accepts the names
+// returned by String.
+func (t *EventType) UnmarshalText(text []byte) error {
+	for typ, name := range eventNames {
+		if name == string(text) {
+			*t = typ
+
+			return nil
+		}
+	}
+
+	return fmt.Errorf("unknown event type '%s'", text)
+}
+
+// Event describes a change to a station.
+type Event struct {
+	Type EventType
+
+	// MAC is the address of the station.
+	MAC string
+
+	// Value is the new value for *Added events.
+	Value string
+
+	// Source is the name of the dissector causing the event.
+	Source string
+
+	// Time is the timestamp of the packet causing the event, or of the
+	// last packet behind a NICSeen event.
+	Time time.Time
+
+	// Interface is the index of the interface the packet was captured on,
+	// or zero if unknown.
+	Interface int
+
+	// Count is the number of packets behind a NICSeen event.
+	Count int
+
+	// NIC is a copy of the station after the packet was processed.
+	NIC *NIC
+}
+
+// Subscription receives events from an Intel. Events are dropped if the
+// subscriber does not keep up.
+type Subscription struct {
+	C <-chan Event
+
+	c       chan Event
+	dropped uint64
+	bus     *bus
+}
+
+// Dropped returns the number of events dropped because the subscriber was
+// too slow.
+func (s *Subscription) Dropped() uint64 {
+	return atomic.LoadUint64(&s.dropped)
+}
+
+// Close unsubscribes and closes C.
+func (s *Subscription) Close() {
+	s.bus.unsubscribe(s)
+}
+
+type bus struct {
+	mu   sync.RWMutex
+	subs []*Subscription
+
+	// closedDropped is the number of events dropped by closed
+	// subscriptions.
+	closedDropped uint64
+}
+
+func (b *bus) subscribe(buffer int) *Subscription {
+	c := make(chan Event, buffer)
+
+	s := &Subscription{
+		C:   c,
+		c:   c,
+		bus: b,
+	}
+
+	b.mu.Lock()
+	b.subs = append(b.subs, s)
+	b.mu.Unlock()
+
+	return s
+}
+
+func (b *bus) unsubscribe(s *Subscription) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for i, sub := range b.subs {
+		if sub == s {
+			b.subs = append(b.subs[:i], b.subs[i+1:]...)
+			b.closedDropped += s.Dropped()
+			close(s.c)
+
+			return
+		}
+	}
+}
+
+// dropped returns the number of events dropped by current and former
+// subscribers.
+func (b *bus) dropped() uint64 {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	total := b.closedDropped
+	for _, s := range b.subs {
+		total += s.Dropped()
+	}
+
+	return total
+}
+
+func (b *bus) publish(e Event) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	for _, s := range b.subs {
+		select {
+		case s.c <- e:
+		default:
+			atomic.AddUint64(&s.dropped, 1)
+		}
+	}
+}
@@ -0,0 +1,730 @@
+// Package intel extracts information about ethernet stations from passively
+// captured packets.
+package intel
+
+import (
+	"net"
+	"net/netip"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	pnlayers "github.com/abrander/pnmap/layers"
+)
+
+// shards is the number of parts stations are spread over by MAC address.
+// Each part has its own lock, so packets from different stations can be
+// dissected in parallel.
+const shards = 64
+
+// Intel collects information about ethernet stations from packets. It is
+// safe for concurrent use. Stations handed out by Intel are copies, and will
+// not change after being returned.
+type Intel struct {
+	shards [shards]*shard
+	bus    bus
+
+	staleAfter   time.Duration
+	seenInterval time.Duration
+
+	// lastStaleCheck and lastSeenFlush are the packet timestamps in
+	// nanoseconds of the last stale check and seen flush.
+	lastStaleCheck int64
+	lastSeenFlush  int64
+
+	// generation is incremented every time stations change in a way that
+	// is saved, which is not on every packet.
+	generation uint64
+
+	packets    uint64
+	discovered uint64
+
+	mux      *mux
+	disabled []string
+}
+
+// shard holds the stations with some of the MAC addresses, and the state of
+// the packet currently being processed for one of them.
+type shard struct {
+	mu    sync.Mutex
+	intel *Intel
+
+	nics NICCollection
+
+	// pending holds the events caused by the packet currently being
+	// processed. They are published when the packet is done.
+	pending []Event
+
+	// timestamp is the timestamp of the packet currently being processed.
+	timestamp time.Time
+
+	// ifindex is the interface index of the packet currently being
+	// processed.
+	ifindex int
+
+	// unpublished holds the stations seen since their last NICSeen event.
+	unpublished []*NIC
+
+	// servers are the IP addresses of DHCP servers named by the current
+	// packet. The servers can be in other shards, so they are marked when
+	// the lock is released.
+	servers []string
+}
+
+// New returns a new Intel ready to process packets.
+func New(opts ...Option) *Intel {
+	i := &Intel{
+		mux:          newMux(),
+		seenInterval: 5 * time.Second,
+	}
+
+	for n := range i.shards {
+		i.shards[n] = &shard{
+			intel: i,
+			nics:  make(NICCollection),
+		}
+	}
+
+	for _, opt := range opts {
+		opt(i)
+	}
+
+	i.mux.add("arp", layers.LayerTypeARP, PriorityLink, (*shard).arp)
+	i.mux.add("cdp", layers.LayerTypeCiscoDiscoveryInfo, PriorityLink, (*shard).ciscoDiscoveryInfo)
+	i.mux.add("ipv4", layers.LayerTypeIPv4, PriorityNetwork, (*shard).ipv4)
+	i.mux.add("ipv6", layers.LayerTypeIPv6, PriorityNetwork, (*shard).ipv6)
+	i.mux.add("ndp", layers.LayerTypeICMPv6NeighborAdvertisement, PriorityNetwork, (*shard).ipv6NeighborAdvertisement)
+	i.mux.add("dhcpv4", layers.LayerTypeDHCPv4, PriorityApplication, (*shard).dhcpv4)
+	i.mux.add("dhcpv6", layers.LayerTypeDHCPv6, PriorityApplication, (*shard).dhcpv6)
+	i.mux.add("mndp", pnlayers.LayerTypeMNDP, PriorityApplication, (*shard).mndp)
+	i.mux.add("ubnt-discovery", pnlayers.LayerTypeUDiscovery, PriorityApplication, (*shard).ubiquitiDiscovery)
+	i.mux.add("nbns", layers.LayerTypeUDP, PriorityApplication, (*shard).nbns, 137)
+	i.mux.add("nbds", layers.LayerTypeUDP, PriorityApplication, (*shard).nbds, 138)
+	i.mux.add("ssdp", layers.LayerTypeUDP, PriorityApplication, (*shard).ssdp, 1900)
+	i.mux.add("hasp", layers.LayerTypeUDP, PriorityApplication, (*shard).hasp, 1947)
+	i.mux.add("ws-discovery", layers.LayerTypeUDP, PriorityApplication, (*shard).wsDiscovery, 3702)
+	i.mux.add("mdns", layers.LayerTypeUDP, PriorityApplication, (*shard).mdns, 5353)
+	i.mux.add("mediaroom", layers.LayerTypeUDP, PriorityApplication, (*shard).mediaroom, 8082)
+	i.mux.add("nobo", layers.LayerTypeUDP, PriorityApplication, (*shard).nobo, 10000, 10001)
+	i.mux.add("dropbox", layers.LayerTypeUDP, PriorityApplication, (*shard).dropbox, 17500)
+	i.mux.add("minecraft", layers.LayerTypeUDP, PriorityApplication, (*shard).minecraft, 19133)
+	i.mux.add("steam", layers.LayerTypeUDP, PriorityApplication, (*shard).steam, 27036)
+	i.mux.add("spotify", layers.LayerTypeUDP, PriorityApplication, (*shard).spotify, 57621)
+
+	for _, name := range i.disabled {
+		_ = i.mux.enable(name, false)
+	}
+
+	return i
+}
+
+// shard returns the shard of the station with the MAC address addr.
+func (i *Intel) shard(addr []byte) *shard {
+	// FNV-1a.
+	h := uint32(2166136261)
+	for _, b := range addr {
+		h ^= uint32(b)
+		h *= 16777619
+	}
+
+	return i.shards[h%shards]
+}
+
+// shardOf is like shard for a formatted MAC address. Stations with invalid
+// addresses, which can only come from state files, are kept in the first
+// shard.
+func (i *Intel) shardOf(mac string) *shard {
+	addr, err := net.ParseMAC(mac)
+	if err != nil {
+		return i.shards[0]
+	}
+
+	return i.shard(addr)
+}
+
+// NICs returns a snapshot of all known stations. Stations are copied a
+// shard at a time, so packets processed meanwhile may be partly included.
+func (i *Intel) NICs() NICCollection {
+	nics := make(NICCollection)
+
+	for _, s := range i.shards {
+		s.mu.Lock()
+		for mac, nic := range s.nics {
+			nics[mac] = nic.Copy()
+		}
+		s.mu.Unlock()
+	}
+
+	return nics
+}
+
+// NIC returns a copy of the station with the given MAC address or nil if
+// the station is unknown.
+func (i *Intel) NIC(mac string) *NIC {
+	s := i.shardOf(mac)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	nic, found := s.nics[mac]
+	if !found {
+		return nil
+	}
+
+	return nic.Copy()
+}
+
+// Subscribe returns a subscription receiving all future events. buffer is the
+// number of events to queue for the subscriber before dropping events.
+func (i *Intel) Subscribe(buffer int) *Subscription {
+	return i.bus.subscribe(buffer)
+}
+
+// Dissectors returns the counters of all dissectors in the order they run.
+func (i *Intel) Dissectors() []DissectorStats {
+	return i.mux.stats()
+}
+
+// EnableDissector turns the named dissector on or off. It is safe to call
+// while packets are being processed.
+func (i *Intel) EnableDissector(name string, enabled bool) error {
+	return i.mux.enable(name, enabled)
+}
+
+// Generation returns a number that changes every time stations change. It
+// can be used to detect changes without comparing snapshots. Packets from
+// known stations only change it when published in NICSeen events, and
+// stations going stale do not change it.
+func (i *Intel) Generation() uint64 {
+	return atomic.LoadUint64(&i.generation)
+}
+
+// Stats is a snapshot of the counters of an Intel.
+type Stats struct {
+	// NICs is the number of known stations.
+	NICs int
+
+	// Packets is the number of ethernet packets processed.
+	Packets uint64
+
+	// Discovered is the number of stations discovered since start.
+	Discovered uint64
+
+	// DroppedEvents is the number of events dropped by slow subscribers.
+	DroppedEvents uint64
+}
+
+// Stats returns the current counters.
+func (i *Intel) Stats() Stats {
+	return Stats{
+		NICs:          i.Len(),
+		Packets:       atomic.LoadUint64(&i.packets),
+		Discovered:    atomic.LoadUint64(&i.discovered),
+		DroppedEvents: i.bus.dropped(),
+	}
+}
+
+// Len returns the number of known stations.
+func (i *Intel) Len() int {
+	n := 0
+
+	for _, s := range i.shards {
+		s.mu.Lock()
+		n += len(s.nics)
+		s.mu.Unlock()
+	}
+
+	return n
+}
+
+func (s *shard) getNIC(addr []byte) *NIC {
+	// Looking up with a converted byte slice does not allocate.
+	var buf [32]byte
+
+	nic, found := s.nics[string(appendMAC(buf[:0], addr))]
+	if !found {
+		nic = newNIC(addr)
+		s.nics[nic.MAC] = nic
+
+		atomic.AddUint64(&s.intel.discovered, 1)
+		s.emit(NICDiscovered, nic, "ethernet", "")
+	}
+
+	return nic
+}
+
+// emit queues an event to be published when the current packet is done.
+func (s *shard) emit(typ EventType, nic *NIC, source string, value string) {
+	if typ != NICStale {
+		atomic.AddUint64(&s.intel.generation, 1)
+	}
+
+	s.pending = append(s.pending, Event{
+		Type:      typ,
+		MAC:       nic.MAC,
+		Value:     value,
+		Source:    source,
+		Time:      s.timestamp,
+		Interface: s.ifindex,
+	})
+}
+
+// emitSeen queues a NICSeen event for every interface nic was seen on since
+// its last NICSeen event.
+func (s *shard) emitSeen(nic *NIC) {
+	atomic.AddUint64(&s.intel.generation, 1)
+
+	for _, u := range nic.unpublished {
+		s.pending = append(s.pending, Event{
+			Type:      NICSeen,
+			MAC:       nic.MAC,
+			Source:    "ethernet",
+			Time:      u.last,
+			Interface: u.ifindex,
+			Count:     u.count,
+		})
+	}
+
+	nic.unpublished = nic.unpublished[:0]
+}
+
+// flushSeen queues NICSeen events for all stations seen since their last
+// NICSeen event. It must be called with the lock held.
+func (s *shard) flushSeen() {
+	for n, nic := range s.unpublished {
+		s.emitSeen(nic)
+		s.unpublished[n] = nil
+	}
+
+	s.unpublished = s.unpublished[:0]
+}
+
+// Flush publishes NICSeen events for stations seen since their last
+// NICSeen event. This happens every seen interval as packets arrive, so
+// Flush is only needed on quiet networks and before stopping.
+func (i *Intel) Flush() {
+	for _, s := range i.shards {
+		s.mu.Lock()
+		s.flushSeen()
+		s.release()
+	}
+}
+
+// flush publishes pending events. Events are given a copy of their station
+// as it looks now. It must be called with the lock held.
+func (s *shard) flush() []Event {
+	events := s.pending
+	s.pending = nil
+
+	if len(events) == 0 {
+		return nil
+	}
+
+	copies := make(map[string]*NIC)
+	for n := range events {
+		mac := events[n].MAC
+
+		nic, found := copies[mac]
+		if !found {
+			nic = s.nics[mac].Copy()
+			copies[mac] = nic
+		}
+
+		events[n].NIC = nic
+	}
+
+	return events
+}
+
+func (s *shard) addIP(nic *NIC, source string, ip string) {
+	if nic.IPs.add(ip, source, s.timestamp) {
+		s.emit(IPAdded, nic, source, ip)
+	}
+}
+
+// addAddr is like addIP for an address from a packet. The address is only
+// formatted if it is new to nic, so packets from known stations do not
+// allocate. Unspecified addresses are ignored.
+func (s *shard) addAddr(nic *NIC, source string, ip net.IP) {
+	addr, ok := netip.AddrFromSlice(ip)
+	if !ok || addr.Unmap().IsUnspecified() {
+		return
+	}
+
+	var buf [64]byte
+
+	value := addr.Unmap().AppendTo(buf[:0])
+	if nic.IPs.touch(value, source, s.timestamp) {
+		return
+	}
+
+	s.addIP(nic, source, string(value))
+}
+
+func (s *shard) addHostname(nic *NIC, source string, hostname string) {
+	if nic.Hostnames.add(hostname, source, s.timestamp) {
+		s.emit(HostnameAdded, nic, source, hostname)
+	}
+}
+
+func (s *shard) addUserAgent(nic *NIC, source string, userAgent string) {
+	if nic.UserAgents.add(userAgent, source, s.timestamp) {
+		s.emit(UserAgentAdded, nic, source, userAgent)
+	}
+}
+
+func (s *shard) addVendor(nic *NIC, source string, vendor string) {
+	if nic.Vendor.add(vendor, source, s.timestamp) {
+		s.emit(VendorAdded, nic, source, vendor)
+	}
+}
+
+func (s *shard) addApplication(nic *NIC, source string, application string) {
+	if nic.Applications.add(application, source, s.timestamp) {
+		s.emit(ApplicationAdded, nic, source, application)
+	}
+}
+
+// CheckStale emits NICStale for every station not seen since the duration
+// set by WithStaleAfter before now. Stations are only reported once until
+// seen again. NewPacket calls CheckStale with the packet timestamp, so this
+// is only needed on quiet networks.
+func (i *Intel) CheckStale(now time.Time) {
+	atomic.StoreInt64(&i.lastStaleCheck, now.UnixNano())
+
+	for _, s := range i.shards {
+		s.mu.Lock()
+		s.checkStale(now)
+		s.release()
+	}
+}
+
+func (s *shard) checkStale(now time.Time) {
+	if s.intel.staleAfter <= 0 {
+		return
+	}
+
+	for _, nic := range s.nics {
+		if !nic.stale && now.Sub(nic.LastSeen) > s.intel.staleAfter {
+			nic.stale = true
+
+			s.emit(NICStale, nic, "", "")
+		}
+	}
+}
+
+// dhcpServer marks the station with the IP address ip as a DHCP server, as
+// of a packet with timestamp captured on ifindex. It must be called without
+// holding the lock of any shard.
+func (i *Intel) dhcpServer(ip string, timestamp time.Time, ifindex int) {
+	for _, s := range i.shards {
+		s.mu.Lock()
+
+		for _, nic := range s.nics {
+			if nic.IPs.Contains(ip) {
+				s.timestamp = timestamp
+				s.ifindex = ifindex
+				s.addApplication(nic, "dhcpv4", "dhcpv4-server")
+				s.release()
+
+				return
+			}
+		}
+
+		s.mu.Unlock()
+	}
+}
+
+func (s *shard) dhcpv4(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	nic := s.getNIC(source)
+	s.addApplication(nic, "dhcpv4", "dhcpv4")
+
+	dhcpv4 := layer.(*layers.DHCPv4)
+	if dhcpv4.Operation != layers.DHCPOpRequest {
+		return false, nil
+	}
+
+	for _, o := range dhcpv4.Options {
+		switch o.Type {
+		case layers.DHCPOptMessageType:
+			if layers.DHCPMsgType(o.Data[0]) == layers.DHCPMsgTypeOffer {
+				s.addApplication(nic, "dhcpv4", "dhcpv4-server")
+			}
+		case layers.DHCPOptClassID:
+			s.addVendor(nic, "dhcpv4", string(o.Data))
+		case layers.DHCPOptHostname:
+			s.addHostname(nic, "dhcpv4", string(o.Data))
+		case layers.DHCPOpt(81): // Client FQDN
+			s.addHostname(nic, "dhcpv4", string(o.Data))
+		case layers.DHCPOptRequestIP:
+			s.addIP(nic, "dhcpv4", net.IP(o.Data).String())
+		case layers.DHCPOptServerID: // Abuse client requests to recognize server.
+			s.servers = append(s.servers, net.IP(o.Data).String())
+		}
+	}
+
+	return true, nil
+}
+
+func (s *shard) dhcpv6(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	nic := s.getNIC(source)
+	s.addApplication(nic, "dhcpv6", "dhcpv6")
+
+	return true, nil
+}
+
+func (s *shard) arp(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	arp := layer.(*layers.ARP)
+
+	nic := s.getNIC(source)
+
+	if len(arp.SourceProtAddress) == 4 {
+		s.addAddr(nic, "arp", arp.SourceProtAddress)
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+func (s *shard) ipv6(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	ipv6 := layer.(*layers.IPv6)
+
+	nic := s.getNIC(source)
+
+	s.addAddr(nic, "ipv6", ipv6.SrcIP)
+
+	return false, nil
+}
+
+func (s *shard) ipv4(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	ipv4 := layer.(*layers.IPv4)
+
+	nic := s.getNIC(source)
+
+	s.addAddr(nic, "ipv4", ipv4.SrcIP)
+
+	return false, nil
+}
+
+func (s *shard) ciscoDiscoveryInfo(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	d := layer.(*layers.CiscoDiscoveryInfo)
+	nic := s.getNIC(source)
+
+	for _, a := range d.Addresses {
+		s.addIP(nic, "cdp", a.String())
+	}
+
+	for _, a := range d.MgmtAddresses {
+		s.addIP(nic, "cdp", a.String())
+	}
+
+	s.addVendor(nic, "cdp", d.Platform)
+	s.addHostname(nic, "cdp", d.DeviceID)
+
+	for _, v := range strings.Split(d.Version, "\n") {
+		if strings.HasPrefix(v, "Cisco IOS Software") {
+			s.addApplication(nic, "cdp", "Cisco IOS")
+		}
+
+		switch {
+		case strings.HasPrefix(v, "Technical Support:"):
+		case strings.HasPrefix(v, "Copyright (c) "):
+
+		default:
+			s.addUserAgent(nic, "cdp", v)
+		}
+	}
+
+	return false, nil
+}
+
+func (s *shard) ipv6NeighborAdvertisement(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	na := layer.(*layers.ICMPv6NeighborAdvertisement)
+	nic := s.getNIC(source)
+
+	s.addAddr(nic, "ndp", na.TargetAddress)
+
+	return true, nil
+}
+
+func (s *shard) mndp(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	mndp := layer.(*pnlayers.MNDP)
+	nic := s.getNIC(source)
+
+	s.addVendor(nic, "mndp", "MikroTek")
+	s.addVendor(nic, "mndp", mndp.Board)
+	s.addHostname(nic, "mndp", mndp.Identity)
+	s.addUserAgent(nic, "mndp", mndp.Platform+"/"+mndp.Version)
+
+	s.addApplication(nic, "mndp", "router")
+
+	return true, nil
+}
+
+func (s *shard) ubiquitiDiscovery(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	ubnt := layer.(*pnlayers.UDiscovery)
+	nic := s.getNIC(source)
+
+	s.addApplication(nic, "ubnt-discovery", "ubnt-discover")
+	s.addVendor(nic, "ubnt-discovery", "Ubiquiti")
+	s.addVendor(nic, "ubnt-discovery", ubnt.Model)
+	s.addUserAgent(nic, "ubnt-discovery", ubnt.Software)
+	s.addIP(nic, "ubnt-discovery", ubnt.IP)
+
+	return true, nil
+}
+
+// NewPacket feeds a packet to the dissectors. It returns true if the packet
+// was recognized by at least one dissector. Events caused by the packet are
+// published after the packet has been processed.
+func (i *Intel) NewPacket(packet gopacket.Packet) bool {
+	ethernetLayer := packet.Layer(layers.LayerTypeEthernet)
+	if ethernetLayer == nil {
+		return false
+	}
+
+	ethernet := ethernetLayer.(*layers.Ethernet)
+
+	return i.dissect(ethernet.SrcMAC, packet.Metadata().CaptureInfo, packet.Layers())
+}
+
+// NewFrame is like NewPacket, but decodes the ethernet frame in data with
+// d. data must not be changed until NewFrame returns, and is not referenced
+// after.
+func (i *Intel) NewFrame(d *Decoder, data []byte, ci gopacket.CaptureInfo) bool {
+	packetLayers, ok := d.decode(data)
+	if !ok {
+		packet := gopacket.NewPacket(data, layers.LayerTypeEthernet, gopacket.NoCopy)
+		packet.Metadata().CaptureInfo = ci
+
+		return i.NewPacket(packet)
+	}
+
+	if len(packetLayers) == 0 {
+		return false
+	}
+
+	return i.dissect(d.ethernet.SrcMAC, ci, packetLayers)
+}
+
+// dissect runs the dissectors on the layers of a packet from source.
+func (i *Intel) dissect(source net.HardwareAddr, ci gopacket.CaptureInfo, packetLayers []gopacket.Layer) bool {
+	s := i.shard(source)
+
+	s.mu.Lock()
+
+	s.timestamp = ci.Timestamp
+	if s.timestamp.IsZero() {
+		s.timestamp = time.Now()
+	}
+
+	s.ifindex = ci.InterfaceIndex
+
+	atomic.AddUint64(&i.packets, 1)
+
+	nic := s.getNIC(source)
+
+	s.seen(nic, 1)
+
+	recognized := i.mux.process(s, source, packetLayers)
+
+	timestamp := s.timestamp
+
+	s.release()
+
+	i.tick(timestamp)
+
+	return recognized
+}
+
+// seen records count packets from nic at the current timestamp. The
+// packets are published in a NICSeen event later. It must be called with
+// the lock held.
+func (s *shard) seen(nic *NIC, count int) {
+	if s.timestamp.After(nic.LastSeen) {
+		nic.LastSeen = s.timestamp
+		nic.stale = false
+	}
+
+	if nic.FirstSeen.IsZero() || s.timestamp.Before(nic.FirstSeen) {
+		nic.FirstSeen = s.timestamp
+	}
+
+	if count == 0 {
+		return
+	}
+
+	nic.Seen += count
+
+	if len(nic.unpublished) == 0 {
+		s.unpublished = append(s.unpublished, nic)
+	}
+
+	for n := range nic.unpublished {
+		u := &nic.unpublished[n]
+		if u.ifindex == s.ifindex {
+			u.count += count
+			if s.timestamp.After(u.last) {
+				u.last = s.timestamp
+			}
+
+			return
+		}
+	}
+
+	nic.unpublished = append(nic.unpublished, unpublished{
+		ifindex: s.ifindex,
+		count:   count,
+		last:    s.timestamp,
+	})
+}
+
+// tick publishes seen stations and checks for stale stations if due at
+// timestamp. Only one caller does the work if called concurrently. It must
+// be called without holding the lock of any shard.
+func (i *Intel) tick(timestamp time.Time) {
+	if due(&i.lastSeenFlush, timestamp, i.seenInterval) {
+		i.Flush()
+	}
+
+	if i.staleAfter > 0 && due(&i.lastStaleCheck, timestamp, time.Minute) {
+		i.CheckStale(timestamp)
+	}
+}
+
+// due returns true if interval has passed between the nanosecond timestamp
+// in last and now, and sets last to now if so.
+func due(last *int64, now time.Time, interval time.Duration) bool {
+	previous := atomic.LoadInt64(last)
+	if now.UnixNano()-previous < int64(interval) {
+		return false
+	}
+
+	return atomic.CompareAndSwapInt64(last, previous, now.UnixNano())
+}
+
+// release releases the lock and publishes pending events. DHCP servers
+// named by the packet are marked after.
+func (s *shard) release() {
+	events := s.flush()
+
+	servers := s.servers
+	s.servers = nil
+
+	timestamp, ifindex := s.timestamp, s.ifindex
+
+	s.mu.Unlock()
+
+	for _, e := range events {
+		s.intel.bus.publish(e)
+	}
+
+	for _, ip := range servers {
+		s.intel.dhcpServer(ip, timestamp, ifindex)
+	}
+}
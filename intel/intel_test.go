@@ -0,0 +1,306 @@
+package intel
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"reflect"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/google/gopacket/pcapgo"
+)
+
+type frame struct {
+	data []byte
+	ci   gopacket.CaptureInfo
+}
+
+// readFrames returns the frames of a pcap file in testdata.
+func readFrames(tb testing.TB, name string) []frame {
+	tb.Helper()
+
+	f, err := os.Open("testdata/" + name)
+	if err != nil {
+		tb.Fatalf("%s", err)
+	}
+	defer f.Close()
+
+	reader, err := pcapgo.NewReader(f)
+	if err != nil {
+		tb.Fatalf("%s: %s", name, err)
+	}
+
+	var frames []frame
+
+	for {
+		data, ci, err := reader.ReadPacketData()
+		if err != nil {
+			break
+		}
+
+		frames = append(frames, frame{data: data, ci: ci})
+	}
+
+	if len(frames) == 0 {
+		tb.Fatalf("%s: no frames", name)
+	}
+
+	return frames
+}
+
+// TestDecoder checks that a Decoder finds the same as gopacket.NewPacket.
+func TestDecoder(t *testing.T) {
+	frames := readFrames(t, "discovery.pcap")
+
+	packets := New()
+	decoded := New()
+	d := NewDecoder()
+
+	for _, f := range frames {
+		packet := gopacket.NewPacket(f.data, layers.LayerTypeEthernet, gopacket.NoCopy)
+		packet.Metadata().CaptureInfo = f.ci
+
+		if packets.NewPacket(packet) != decoded.NewFrame(d, f.data, f.ci) {
+			t.Errorf("recognized differently: %s", packet)
+		}
+	}
+
+	if packets.Len() == 0 {
+		t.Fatal("no stations found")
+	}
+
+	if !reflect.DeepEqual(packets.NICs(), decoded.NICs()) {
+		t.Errorf("stations differ")
+	}
+
+	for _, s := range decoded.Dissectors() {
+		if s.Errors > 0 {
+			t.Errorf("dissector %s failed %d times", s.Name, s.Errors)
+		}
+	}
+}
+
+// TestDissectAllocs checks that frames from known stations do not allocate
+// when decoded by a Decoder, unless an application payload is dissected.
+func TestDissectAllocs(t *testing.T) {
+	frames := readFrames(t, "discovery.pcap")
+
+	i := New()
+	d := NewDecoder()
+
+	for _, f := range frames {
+		i.NewFrame(d, f.data, f.ci)
+	}
+
+	tested := 0
+
+	for n, f := range frames {
+		packetLayers, ok := d.decode(f.data)
+		if !ok || len(packetLayers) == 0 || slices.ContainsFunc(packetLayers, func(l gopacket.Layer) bool {
+			return l.LayerType() == layers.LayerTypeUDP
+		}) {
+			continue
+		}
+
+		tested++
+
+		allocs := testing.AllocsPerRun(100, func() {
+			i.NewFrame(d, f.data, f.ci)
+		})
+		if allocs > 0 {
+			t.Errorf("frame %d allocates %.0f times", n, allocs)
+		}
+	}
+
+	if tested == 0 {
+		t.Fatal("no frames tested")
+	}
+}
+
+// BenchmarkDissect dissects frames from stations already known, like a
+// long running capture, with gopacket.NewPacket and with a Decoder.
+func BenchmarkDissect(b *testing.B) {
+	frames := readFrames(b, "discovery.pcap")
+
+	paths := []struct {
+		name string
+		run  func(i *Intel, f frame) bool
+	}{
+		{"packet", func(i *Intel, f frame) bool {
+			packet := gopacket.NewPacket(f.data, layers.LayerTypeEthernet, gopacket.NoCopy)
+			packet.Metadata().CaptureInfo = f.ci
+
+			return i.NewPacket(packet)
+		}},
+		{"decoder", func() func(i *Intel, f frame) bool {
+			d := NewDecoder()
+
+			return func(i *Intel, f frame) bool {
+				return i.NewFrame(d, f.data, f.ci)
+			}
+		}()},
+	}
+
+	for _, p := range paths {
+		b.Run(p.name, func(b *testing.B) {
+			i := New()
+
+			for _, f := range frames {
+				p.run(i, f)
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			for n := 0; n < b.N; n++ {
+				p.run(i, frames[n%len(frames)])
+			}
+		})
+	}
+}
+
+// BenchmarkDissectWorkers dissects frames in parallel workers, each with
+// its own stations, like the capture pipeline. Workers do not share shards,
+// so the time per frame should drop with more workers, up to the number of
+// CPUs.
+func BenchmarkDissectWorkers(b *testing.B) {
+	frames := readFrames(b, "discovery.pcap")
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			i := New()
+
+			// Every worker gets the frames from its own stations, picked
+			// like the capture pipeline assigns stations to workers.
+			own := make([][]frame, workers)
+			for w := range own {
+				for _, f := range frames {
+					data := append([]byte(nil), f.data...)
+
+					for n := 0; ; n++ {
+						data[6], data[7] = 0x02, byte(n)
+						if slices.Index(i.shards[:], i.shard(data[6:12]))%workers == w {
+							break
+						}
+					}
+
+					own[w] = append(own[w], frame{data: data, ci: f.ci})
+				}
+			}
+
+			for w := range own {
+				d := NewDecoder()
+
+				for _, f := range own[w] {
+					i.NewFrame(d, f.data, f.ci)
+				}
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+
+			var wg sync.WaitGroup
+
+			for w := range own {
+				wg.Add(1)
+
+				go func(frames []frame) {
+					defer wg.Done()
+
+					d := NewDecoder()
+
+					for n := 0; n < b.N/workers; n++ {
+						i.NewFrame(d, frames[n%len(frames)].data, frames[n%len(frames)].ci)
+					}
+				}(own[w])
+			}
+
+			wg.Wait()
+		})
+	}
+}
+
+// TestDHCPServer checks that a server named in a DHCP request from another
+// station is recognized, wherever the stations are kept.
+func TestDHCPServer(t *testing.T) {
+	server, _ := net.ParseMAC("02:00:00:00:00:01")
+	client, _ := net.ParseMAC("02:00:00:00:00:02")
+
+	i := New()
+
+	if i.shard(server) == i.shard(client) {
+		t.Fatal("the stations should be in different shards")
+	}
+
+	i.Merge(Fact{Type: IPAdded, MAC: server.String(), Source: "arp", Value: "192.0.2.1"})
+
+	sub := i.Subscribe(100)
+	defer sub.Close()
+
+	buf := gopacket.NewSerializeBuffer()
+
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true},
+		&layers.Ethernet{SrcMAC: client, DstMAC: layers.EthernetBroadcast, EthernetType: layers.EthernetTypeIPv4},
+		&layers.IPv4{Version: 4, TTL: 64, Protocol: layers.IPProtocolUDP, SrcIP: net.IPv4zero, DstIP: net.IPv4bcast},
+		&layers.UDP{SrcPort: 68, DstPort: 67},
+		&layers.DHCPv4{
+			Operation:    layers.DHCPOpRequest,
+			HardwareType: layers.LinkTypeEthernet,
+			HardwareLen:  6,
+			ClientHWAddr: client,
+			Options: layers.DHCPOptions{
+				layers.NewDHCPOption(layers.DHCPOptMessageType, []byte{byte(layers.DHCPMsgTypeRequest)}),
+				layers.NewDHCPOption(layers.DHCPOptServerID, []byte{192, 0, 2, 1}),
+				layers.NewDHCPOption(layers.DHCPOptEnd, nil),
+			},
+		},
+	)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	i.NewFrame(NewDecoder(), buf.Bytes(), gopacket.CaptureInfo{Timestamp: time.Now()})
+
+	if nic := i.NIC(server.String()); nic == nil || !nic.Applications.Contains("dhcpv4-server") {
+		t.Fatalf("server not recognized: %+v", nic)
+	}
+
+	for {
+		select {
+		case e := <-sub.C:
+			if e.Type == ApplicationAdded && e.MAC == server.String() && e.Value == "dhcpv4-server" {
+				return
+			}
+
+		default:
+			t.Fatal("no event for the server")
+		}
+	}
+}
+
+// TestWithNICs checks that preloaded stations are not shared with the
+// caller.
+func TestWithNICs(t *testing.T) {
+	nics := NICCollection{
+		"02:00:00:00:00:01": {
+			MAC:  "02:00:00:00:00:01",
+			IPs:  Observations{{Value: "192.0.2.1", Source: "arp", Count: 1}},
+			Seen: 1,
+		},
+	}
+
+	i := New(WithNICs(nics))
+
+	nics["02:00:00:00:00:01"].Seen = 100
+	nics["02:00:00:00:00:01"].IPs[0].Count = 100
+
+	nic := i.NIC("02:00:00:00:00:01")
+	if nic == nil || nic.Seen != 1 || nic.IPs[0].Count != 1 {
+		t.Errorf("station changed by the caller: %+v", nic)
+	}
+}
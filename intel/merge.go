@@ -0,0 +1,93 @@
+package intel
+
+import (
+	"net"
+	"sync/atomic"
+	"time"
+)
+
+// Fact is an observation about a station made elsewhere, like by a remote
+// sensor.
+type Fact struct {
+	// Type is the type of the event the fact was derived from. NICStale
+	// facts are ignored, staleness is decided locally.
+	Type EventType
+
+	MAC    string
+	Value  string
+	Source string
+	Time   time.Time
+
+	// Count is the number of packets for NICSeen facts.
+	Count int
+
+	// Sensor is the sensor and interface the fact was observed by, like
+	// "office/eth0". It is added to the Sensors of the station.
+	Sensor string
+}
+
+// Merge applies facts as if the packets behind them were processed here.
+// Events are emitted like for packets. Facts with invalid MAC addresses are
+// ignored.
+func (i *Intel) Merge(facts ...Fact) {
+	for _, f := range facts {
+		addr, err := net.ParseMAC(f.MAC)
+		if err != nil {
+			continue
+		}
+
+		s := i.shard(addr)
+
+		s.mu.Lock()
+
+		s.ifindex = 0
+
+		s.timestamp = f.Time
+		if s.timestamp.IsZero() {
+			s.timestamp = time.Now()
+		}
+
+		count := f.Count
+		if count < 1 {
+			count = 1
+		}
+
+		nic := s.getNIC(addr)
+
+		if f.Sensor != "" {
+			nic.Sensors.addCount(f.Sensor, "sensor", s.timestamp, count)
+		}
+
+		switch f.Type {
+		case NICDiscovered:
+			if nic.FirstSeen.IsZero() {
+				s.seen(nic, 0)
+			}
+
+		case NICSeen:
+			atomic.AddUint64(&i.packets, uint64(count))
+			s.seen(nic, count)
+
+		case IPAdded:
+			s.addIP(nic, f.Source, f.Value)
+
+		case HostnameAdded:
+			s.addHostname(nic, f.Source, f.Value)
+
+		case UserAgentAdded:
+			s.addUserAgent(nic, f.Source, f.Value)
+
+		case VendorAdded:
+			s.addVendor(nic, f.Source, f.Value)
+
+		case ApplicationAdded:
+			s.addApplication(nic, f.Source, f.Value)
+		}
+
+		timestamp := s.timestamp
+
+		s.release()
+
+		i.tick(timestamp)
+	}
+}
@@ -0,0 +1,150 @@
+package intel
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+)
+
+// Observation is a single fact about a station along with the evidence for
+// it. Timestamps are taken from the packets asserting the fact.
+type Observation struct {
+	Value     string    `json:"Value"`
+	Source    string    `json:"Source"`
+	FirstSeen time.Time `json:"FirstSeen"`
+	LastSeen  time.Time `json:"LastSeen"`
+	Count     int       `json:"Count"`
+}
+
+// Observations is a list of observations. A value asserted by more than one
+// source will have an observation per source.
+type Observations []Observation
+
+// add records value as observed by source at ts. It returns true if the
+// value was not known before from any source.
+func (o *Observations) add(value string, source string, ts time.Time) bool {
+	return o.addCount(value, source, ts, 1)
+}
+
+// addCount is like add, but records count observations at once.
+func (o *Observations) addCount(value string, source string, ts time.Time, count int) bool {
+	if len(value) == 0 {
+		return false
+	}
+
+	for i := range *o {
+		obs := &(*o)[i]
+		if obs.Value == value && obs.Source == source {
+			obs.Count += count
+			if ts.After(obs.LastSeen) {
+				obs.LastSeen = ts
+			}
+
+			if ts.Before(obs.FirstSeen) {
+				obs.FirstSeen = ts
+			}
+
+			return false
+		}
+	}
+
+	known := o.Contains(value)
+
+	*o = append(*o, Observation{
+		Value:     value,
+		Source:    source,
+		FirstSeen: ts,
+		LastSeen:  ts,
+		Count:     count,
+	})
+
+	return !known
+}
+
+// touch is like add for a value already observed from source, but takes
+// the value as bytes so known values cost no allocation. It returns false
+// if value has not been observed from source.
+func (o Observations) touch(value []byte, source string, ts time.Time) bool {
+	for i := range o {
+		obs := &o[i]
+		if obs.Value == string(value) && obs.Source == source {
+			obs.Count++
+			if ts.After(obs.LastSeen) {
+				obs.LastSeen = ts
+			}
+
+			if ts.Before(obs.FirstSeen) {
+				obs.FirstSeen = ts
+			}
+
+			return true
+		}
+	}
+
+	return false
+}
+
+// Contains returns true if value has been observed.
+func (o Observations) Contains(value string) bool {
+	for _, obs := range o {
+		if obs.Value == value {
+			return true
+		}
+	}
+
+	return false
+}
+
+// Values returns the unique observed values in the order they were first
+// seen.
+func (o Observations) Values() []string {
+	values := make([]string, 0, len(o))
+	seen := make(map[string]bool, len(o))
+
+	for _, obs := range o {
+		if !seen[obs.Value] {
+			seen[obs.Value] = true
+			values = append(values, obs.Value)
+		}
+	}
+
+	return values
+}
+
+func (o Observations) copy() Observations {
+	if o == nil {
+		return nil
+	}
+
+	return append(Observations(nil), o...)
+}
+
+// UnmarshalJSON reads observations, and falls back to the plain list of
+// strings used by older state files.
+func (o *Observations) UnmarshalJSON(data []byte) error {
+	var observations []Observation
+
+	err := json.Unmarshal(data, &observations)
+	if err == nil {
+		*o = observations
+
+		return nil
+	}
+
+	var values []string
+
+	if json.Unmarshal(data, &values) != nil {
+		return err
+	}
+
+	*o = nil
+	for _, v := range values {
+		*o = append(*o, Observation{Value: v, Count: 1})
+	}
+
+	return nil
+}
+
+func (o Observations) String() string {
+	return strings.Join(o.Values(), ", ")
+}
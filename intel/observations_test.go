@@ -0,0 +1,80 @@
+package intel
+
+import (
+	"encoding/json"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+)
+
+// TestObservationsBaseline checks that a state file written before
+// observations had sources and timestamps still loads.
+func TestObservationsBaseline(t *testing.T) {
+	data, err := os.ReadFile("testdata/baseline-state.json")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	var nics NICCollection
+
+	err = json.Unmarshal(data, &nics)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if len(nics) != 2 {
+		t.Fatalf("loaded %d stations, expected 2", len(nics))
+	}
+
+	nas := nics["00:11:32:aa:bb:cc"]
+	if nas == nil {
+		t.Fatal("station not loaded")
+	}
+
+	first := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	last := time.Date(2024, 5, 3, 8, 30, 0, 0, time.UTC)
+
+	expected := Observations{
+		{Value: "192.168.1.10", FirstSeen: first, LastSeen: last, Count: 1},
+		{Value: "fe80::211:32ff:feaa:bbcc", FirstSeen: first, LastSeen: last, Count: 1},
+	}
+
+	if !reflect.DeepEqual(nas.IPs, expected) {
+		t.Errorf("got IPs %+v, expected %+v", nas.IPs, expected)
+	}
+
+	if !reflect.DeepEqual(nas.Applications.Values(), []string{"mdns", "dhcpv4-client"}) {
+		t.Errorf("got applications %v", nas.Applications.Values())
+	}
+
+	if nas.UserAgents != nil || nas.Seen != 1234 {
+		t.Errorf("got %+v", nas)
+	}
+
+	if pi := nics["b8:27:eb:01:02:03"]; pi == nil || pi.Hostnames != nil || !pi.IPs.Contains("192.168.1.20") {
+		t.Errorf("got %+v", pi)
+	}
+
+	// A value seen again from a known source is not new.
+	if nas.IPs.add("192.168.1.10", "arp", last.Add(time.Hour)) {
+		t.Error("known address reported as new")
+	}
+
+	// Once saved in the new format, the stations load unchanged.
+	data, err = json.Marshal(nics)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	var again NICCollection
+
+	err = json.Unmarshal(data, &again)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if !reflect.DeepEqual(nics, again) {
+		t.Error("stations changed when saved and loaded again")
+	}
+}
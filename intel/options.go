@@ -0,0 +1,41 @@
+package intel
+
+import (
+	"time"
+)
+
+// Option configures an Intel.
+type Option func(*Intel)
+
+// WithNICs preloads an Intel with copies of previously collected stations,
+// so the caller may keep using nics.
+func WithNICs(nics NICCollection) Option {
+	return func(i *Intel) {
+		for mac, nic := range nics {
+			i.shardOf(mac).nics[mac] = nic.Copy()
+		}
+	}
+}
+
+// WithStaleAfter enables NICStale events for stations not seen for d.
+func WithStaleAfter(d time.Duration) Option {
+	return func(i *Intel) {
+		i.staleAfter = d
+	}
+}
+
+// WithSeenInterval sets how often NICSeen events are published for a
+// station sending packets. It defaults to five seconds.
+func WithSeenInterval(d time.Duration) Option {
+	return func(i *Intel) {
+		i.seenInterval = d
+	}
+}
+
+// WithDisabledDissectors turns off the named dissectors. Unknown names are
+// ignored.
+func WithDisabledDissectors(names ...string) Option {
+	return func(i *Intel) {
+		i.disabled = append(i.disabled, names...)
+	}
+}
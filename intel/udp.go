@@ -0,0 +1,333 @@
+package intel
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+	"github.com/miekg/dns"
+)
+
+// mdnsApplications maps mDNS service names to applications.
+var mdnsApplications = map[string]string{
+	"_sftp-ssh":        "SSH",
+	"_smb":             "Samba",
+	"_ipp":             "IPP",
+	"_ipps":            "IPPS",
+	"_pdl-datastream":  "PDL-socket",
+	"_afpovertcp":      "AFP",          // Apple Filing Protocol
+	"_raop":            "AirPlay-RAOP", // Remote Audio Output Protocol
+	"_airplay":         "AirPlay-display",
+	"_companion-link":  "AirPlay-client",
+	"_services":        "",
+	"_nvstream_dbd":    "NVidia-Gamestream",
+	"_homekit":         "homekit?",
+	"_ePCL":            "ePCL?",
+	"_universal":       "universal?",
+	"_print":           "print?",
+	"_wfds-print":      "wfds-print?",
+	"_printer":         "LPR-printer",
+	"_http":            "HTTP-server",
+	"_scanner":         "Scanner",
+	"_http-alt":        "HTTP-server-alt",
+	"_uscan":           "uscan?",
+	"_privet":          "Privet",
+	"_uscans":          "uscans?",
+	"_soundtouch":      "SoundTouch", // Bose
+	"_googlecast":      "Chromecast",
+	"_spotify-connect": "Spotify-Connect",
+	"_teamviewer":      "TeamViewer",
+	"_rfb":             "VNC",
+	"_adisk":           "TimeCapsule",
+	"_telnet":          "Telnet",
+	"_sonos":           "Sonos",
+	"_cros_p2p":        "ChromeOS",
+}
+
+// NBNS
+func (s *shard) nbns(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	nic := s.getNIC(source)
+	s.addApplication(nic, "nbns", "NetBIOS-Name-Service")
+
+	return true, nil
+}
+
+// NBDS - SMB
+func (s *shard) nbds(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	nic := s.getNIC(source)
+	s.addApplication(nic, "nbds", "NetBIOS-Datagram-Service")
+
+	return true, nil
+}
+
+// SSDP
+func (s *shard) ssdp(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	udp := layer.(*layers.UDP)
+	nic := s.getNIC(source)
+
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(udp.Payload)))
+	if err != nil {
+		return false, err
+	}
+
+	ua := req.Header.Get("user-agent")
+	if ua != "" {
+		s.addUserAgent(nic, "ssdp", ua)
+	}
+
+	return true, nil
+}
+
+// HASP License Manager
+func (s *shard) hasp(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	nic := s.getNIC(source)
+	s.addApplication(nic, "hasp", "HASP-License-Manager")
+
+	return true, nil
+}
+
+// WS-Discovery
+func (s *shard) wsDiscovery(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	nic := s.getNIC(source)
+	s.addApplication(nic, "ws-discovery", "WS-Discovery")
+
+	return true, nil
+}
+
+// Multicast-DNS
+func (s *shard) mdns(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	udp := layer.(*layers.UDP)
+	nic := s.getNIC(source)
+
+	msg := new(dns.Msg)
+
+	dnsParts := func(in string) []string {
+		in = strings.TrimSuffix(in, ".local.")
+
+		var parts []string
+		var part strings.Builder
+
+		var r rune
+		for i, w := 0, 0; i < len(in); i += w {
+			r, w = utf8.DecodeRuneInString(in[i:])
+			if r == '\\' {
+				var w2 int
+				r, w2 = utf8.DecodeRuneInString(in[i+w:])
+				w += w2
+				part.WriteRune(r)
+			} else if r == '.' {
+				parts = append(parts, part.String())
+				part.Reset()
+			} else {
+				part.WriteRune(r)
+			}
+		}
+
+		return append(parts, part.String())
+	}
+
+	if err := msg.Unpack(udp.Payload); err != nil {
+		return false, err
+	}
+
+	if !msg.Response {
+		return true, nil
+	}
+
+	for _, answer := range msg.Answer {
+		names := dnsParts(answer.Header().Name)
+		switch rr := answer.(type) {
+		case *dns.A:
+			name := strings.TrimSuffix(rr.Header().Name, ".local.")
+
+			s.addHostname(nic, "mdns", name)
+
+		case *dns.PTR:
+			app, found := mdnsApplications[names[0]]
+			if !found {
+				app = names[0]
+			}
+
+			if strings.HasSuffix(rr.Header().Name, ".arpa.") {
+				break
+			}
+
+			if app != "" {
+				s.addApplication(nic, "mdns", app)
+			}
+
+		case *dns.SRV:
+			if len(names) < 2 {
+				break
+			}
+
+			app, found := mdnsApplications[names[1]]
+			if !found {
+				app = names[1]
+			}
+
+			if app != "" {
+				s.addApplication(nic, "mdns", app)
+			}
+
+			if names[0] != "" && names[0][0] != '_' {
+				s.addHostname(nic, "mdns", names[0])
+			}
+
+		case *dns.TXT:
+			s.addHostname(nic, "mdns", names[0])
+			if len(names) > 1 && names[1] == "_device-info" && len(rr.Txt) > 0 {
+				if strings.HasPrefix(rr.Txt[0], "model=") {
+					s.addVendor(nic, "mdns", appleHumanModel(rr.Txt[0][6:]))
+				} else {
+					s.addVendor(nic, "mdns", rr.Txt[0])
+				}
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// Mediaroom set top box
+func (s *shard) mediaroom(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	udp := layer.(*layers.UDP)
+
+	if bytes.Contains(udp.Payload, []byte("x-type: display")) {
+		nic := s.getNIC(source)
+		s.addApplication(nic, "mediaroom", "Mediaroom")
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// Nobø Hub
+// https://www.glendimplex.se/media/15650/nobo-hub-api-v-1-1-integration-for-advanced-users.pdf
+func (s *shard) nobo(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	udp := layer.(*layers.UDP)
+
+	if bytes.Contains(udp.Payload, []byte("__NOBOHUB__")) {
+		nic := s.getNIC(source)
+		s.addVendor(nic, "nobo", "Glen-Dimplex")
+		s.addApplication(nic, "nobo", "nobo")
+
+		return true, nil
+	}
+
+	return false, nil
+}
+
+// Dropbox
+func (s *shard) dropbox(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	udp := layer.(*layers.UDP)
+
+	dummy := make(map[string]interface{})
+	err := json.Unmarshal(udp.Payload, &dummy)
+	if err != nil {
+		return false, err
+	}
+
+	// If we can decode a JSON payload, we assume it's from Dropbox.
+	nic := s.getNIC(source)
+	s.addApplication(nic, "dropbox", "Dropbox")
+
+	return true, nil
+}
+
+// Raknet for Minecraft client
+func (s *shard) minecraft(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	nic := s.getNIC(source)
+	s.addApplication(nic, "minecraft", "Minecraft")
+
+	return true, nil
+}
+
+// Steam client
+func (s *shard) steam(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	udp := layer.(*layers.UDP)
+	nic := s.getNIC(source)
+
+	s.addApplication(nic, "steam", "Steam")
+
+	if len(udp.Payload) < 40 {
+		return false, errors.New("steam: short payload")
+	}
+
+	hlen, err := binary.ReadUvarint(bytes.NewBuffer(udp.Payload[8:]))
+	if err != nil {
+		return false, err
+	}
+
+	// 8: skip 8 byts of signature
+	buf := proto.NewBuffer(udp.Payload[8:])
+
+	// signature + header length + header + body length
+	offset := 8 + 4 + hlen + 4
+
+	if offset > uint64(len(udp.Payload)) {
+		return false, errors.New("steam: header length out of bounds")
+	}
+
+	buf.SetBuf(udp.Payload[offset:])
+
+	for value, err := buf.DecodeVarint(); err == nil; value, err = buf.DecodeVarint() {
+		number := value >> 3
+		typ := value & 0x7
+
+		var str string
+
+		switch typ {
+		case 0:
+			_, err = buf.DecodeVarint()
+		case 1:
+			_, err = buf.DecodeFixed64()
+		case 2:
+			str, err = buf.DecodeStringBytes()
+		case 5:
+			_, err = buf.DecodeFixed32()
+		default:
+			return false, errors.New("steam: unknown wire type")
+		}
+
+		if err != nil {
+			break
+		}
+
+		switch number {
+		case 4:
+			s.addHostname(nic, "steam", str)
+
+		case 20, 21:
+			if str != "0.0.0.0" {
+				s.addIP(nic, "steam", str)
+			}
+		}
+	}
+
+	return true, nil
+}
+
+// Spotify
+func (s *shard) spotify(source net.HardwareAddr, layer gopacket.Layer) (bool, error) {
+	udp := layer.(*layers.UDP)
+
+	if bytes.HasPrefix(udp.Payload, []byte("SpotUdp")) {
+		nic := s.getNIC(source)
+		s.addApplication(nic, "spotify", "Spotify")
+
+		return true, nil
+	}
+
+	return false, nil
+}
@@ -1,18 +1,23 @@
-package main
+// Package layers implements gopacket decoders for discovery protocols not
+// covered by gopacket itself.
+package layers
 
 import (
 	"encoding/binary"
+	"errors"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 )
 
-var layerTypeMNDP = gopacket.RegisterLayerType(0x1001, gopacket.LayerTypeMetadata{Name: "MNDP", Decoder: gopacket.DecodeFunc(decodeMNDP)})
+// LayerTypeMNDP is the layer type of MikroTik Neighbor Discovery packets.
+var LayerTypeMNDP = gopacket.RegisterLayerType(0x1001, gopacket.LayerTypeMetadata{Name: "MNDP", Decoder: gopacket.DecodeFunc(decodeMNDP)})
 
 func init() {
-	layers.RegisterUDPPortLayerType(layers.UDPPort(5678), layerTypeMNDP)
+	layers.RegisterUDPPortLayerType(layers.UDPPort(5678), LayerTypeMNDP)
 }
 
+// MNDP is a decoded MikroTik Neighbor Discovery Protocol announcement.
 type MNDP struct {
 	unknownHeader1 [2]byte
 	unknownHeader2 [2]byte
@@ -29,13 +34,36 @@ type MNDP struct {
 	contents []byte
 }
 
-func (m *MNDP) LayerType() gopacket.LayerType { return layerTypeMNDP }
+func (m *MNDP) LayerType() gopacket.LayerType { return LayerTypeMNDP }
 func (m *MNDP) LayerContents() []byte         { return m.contents }
 func (m *MNDP) LayerPayload() []byte          { return nil }
 
+func (m *MNDP) CanDecode() gopacket.LayerClass    { return LayerTypeMNDP }
+func (m *MNDP) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
 func decodeMNDP(data []byte, p gopacket.PacketBuilder) error {
 	m := &MNDP{}
 
+	err := m.DecodeFromBytes(data, p)
+	if err != nil {
+		return err
+	}
+
+	p.AddLayer(m)
+
+	return p.NextDecoder(gopacket.DecodeFragment)
+}
+
+// DecodeFromBytes decodes data into m, replacing all fields.
+func (m *MNDP) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	*m = MNDP{contents: data}
+
+	if len(data) < 4 {
+		df.SetTruncated()
+
+		return errors.New("MNDP packet too short")
+	}
+
 	// Well...
 	copy(m.unknownHeader1[:], data)
 	copy(m.unknownHeader2[:], data[2:])
@@ -65,7 +93,9 @@ func decodeMNDP(data []byte, p gopacket.PacketBuilder) error {
 		case 8: // Platform, string
 			m.Platform = string(payload)
 		case 10: // Uptime, uint16
-			m.Uptime = binary.BigEndian.Uint16(payload)
+			if len(payload) >= 2 {
+				m.Uptime = binary.BigEndian.Uint16(payload)
+			}
 		case 11: // Software ID, string
 			m.SoftwareID = string(payload)
 		case 12: // Board, string
@@ -79,7 +109,5 @@ func decodeMNDP(data []byte, p gopacket.PacketBuilder) error {
 		}
 	}
 
-	p.AddLayer(m)
-
-	return p.NextDecoder(gopacket.DecodeFragment)
+	return nil
 }
@@ -1,19 +1,24 @@
-package main
+package layers
 
 import (
 	"encoding/binary"
+	"errors"
 	"net"
 
 	"github.com/google/gopacket"
 	"github.com/google/gopacket/layers"
 )
 
-var layerTypeUDiscovery = gopacket.RegisterLayerType(0x1002, gopacket.LayerTypeMetadata{Name: "ubqt-discovery", Decoder: gopacket.DecodeFunc(decodeUbiquityDiscovery)})
+// LayerTypeUDiscovery is the layer type of Ubiquiti discovery packets.
+var LayerTypeUDiscovery = gopacket.RegisterLayerType(0x1002, gopacket.LayerTypeMetadata{Name: "ubqt-discovery", Decoder: gopacket.DecodeFunc(decodeUbiquityDiscovery)})
 
 func init() {
-	layers.RegisterUDPPortLayerType(layers.UDPPort(10001), layerTypeUDiscovery)
+	layers.RegisterUDPPortLayerType(layers.UDPPort(10001), LayerTypeUDiscovery)
 }
 
+var errMalformed = errors.New("malformed Ubiquiti discovery packet")
+
+// UDiscovery is a decoded Ubiquiti discovery announcement.
 type UDiscovery struct {
 	Software string
 	IP       string
@@ -23,18 +28,38 @@ type UDiscovery struct {
 	Series   string
 }
 
-func (m *UDiscovery) LayerType() gopacket.LayerType { return layerTypeUDiscovery }
+func (m *UDiscovery) LayerType() gopacket.LayerType { return LayerTypeUDiscovery }
 func (m *UDiscovery) LayerContents() []byte         { return nil }
 func (m *UDiscovery) LayerPayload() []byte          { return nil }
 
+func (m *UDiscovery) CanDecode() gopacket.LayerClass    { return LayerTypeUDiscovery }
+func (m *UDiscovery) NextLayerType() gopacket.LayerType { return gopacket.LayerTypeZero }
+
 func decodeUbiquityDiscovery(data []byte, p gopacket.PacketBuilder) error {
 	u := &UDiscovery{}
 
+	err := u.DecodeFromBytes(data, p)
+	if err != nil {
+		return p.NextDecoder(gopacket.DecodePayload)
+	}
+
+	p.AddLayer(u)
+
+	return p.NextDecoder(gopacket.DecodeFragment)
+}
+
+// DecodeFromBytes decodes data into u, replacing all fields.
+func (u *UDiscovery) DecodeFromBytes(data []byte, df gopacket.DecodeFeedback) error {
+	*u = UDiscovery{}
+
+	if len(data) < 4 {
+		return errMalformed
+	}
+
 	dataLength := int(binary.BigEndian.Uint16(data[2:]))
 
 	if dataLength+4 != len(data) {
-
-		return p.NextDecoder(gopacket.DecodePayload)
+		return errMalformed
 	}
 
 	rest := data[4:]
@@ -52,8 +77,7 @@ func decodeUbiquityDiscovery(data []byte, p gopacket.PacketBuilder) error {
 
 			rest = rest[length:]
 		} else {
-			// malformed.
-			return p.NextDecoder(gopacket.DecodePayload)
+			return errMalformed
 		}
 
 		// Well, this is based on samples from a handful Ubiquiti
@@ -66,7 +90,9 @@ func decodeUbiquityDiscovery(data []byte, p gopacket.PacketBuilder) error {
 		case 4: // IP address, 4 bytes
 			u.IP = net.IP(payload).String()
 		case 10: // Uptime, uint32
-			u.Uptime = int(binary.BigEndian.Uint32(payload))
+			if len(payload) >= 4 {
+				u.Uptime = int(binary.BigEndian.Uint32(payload))
+			}
 		case 11: // name, string
 			u.Name = string(payload)
 		case 12: // model, string
@@ -78,7 +104,5 @@ func decodeUbiquityDiscovery(data []byte, p gopacket.PacketBuilder) error {
 		}
 	}
 
-	p.AddLayer(u)
-
-	return p.NextDecoder(gopacket.DecodeFragment)
+	return nil
 }
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+var (
+	nicsDesc = prometheus.NewDesc(
+		namespace+"_nics",
+		"Number of known stations.",
+		nil, nil,
+	)
+
+	discoveredDesc = prometheus.NewDesc(
+		namespace+"_nics_discovered_total",
+		"Stations discovered since start. Use rate() for new stations per minute.",
+		nil, nil,
+	)
+
+	droppedEventsDesc = prometheus.NewDesc(
+		namespace+"_events_dropped_total",
+		"Change events dropped because a subscriber was too slow.",
+		nil, nil,
+	)
+
+	dissectorSeenDesc = prometheus.NewDesc(
+		namespace+"_dissector_seen_total",
+		"Layers offered to a dissector.",
+		[]string{"dissector"}, nil,
+	)
+
+	dissectorRecognizedDesc = prometheus.NewDesc(
+		namespace+"_dissector_recognized_total",
+		"Layers recognized by a dissector.",
+		[]string{"dissector"}, nil,
+	)
+
+	dissectorErrorsDesc = prometheus.NewDesc(
+		namespace+"_dissector_errors_total",
+		"Errors returned by a dissector.",
+		[]string{"dissector"}, nil,
+	)
+
+	dissectorSecondsDesc = prometheus.NewDesc(
+		namespace+"_dissector_seconds_total",
+		"Time spent in a dissector.",
+		[]string{"dissector"}, nil,
+	)
+
+	dissectorEnabledDesc = prometheus.NewDesc(
+		namespace+"_dissector_enabled",
+		"Whether a dissector is enabled.",
+		[]string{"dissector"}, nil,
+	)
+)
+
+// intelCollector reads counters from an Intel at scrape time.
+type intelCollector struct {
+	intel *intel.Intel
+}
+
+func (c *intelCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- nicsDesc
+	ch <- discoveredDesc
+	ch <- droppedEventsDesc
+	ch <- dissectorSeenDesc
+	ch <- dissectorRecognizedDesc
+	ch <- dissectorErrorsDesc
+	ch <- dissectorSecondsDesc
+	ch <- dissectorEnabledDesc
+}
+
+func (c *intelCollector) Collect(ch chan<- prometheus.Metric) {
+	stats := c.intel.Stats()
+
+	ch <- prometheus.MustNewConstMetric(nicsDesc, prometheus.GaugeValue, float64(stats.NICs))
+	ch <- prometheus.MustNewConstMetric(discoveredDesc, prometheus.CounterValue, float64(stats.Discovered))
+	ch <- prometheus.MustNewConstMetric(droppedEventsDesc, prometheus.CounterValue, float64(stats.DroppedEvents))
+
+	for _, d := range c.intel.Dissectors() {
+		enabled := 0.0
+		if d.Enabled {
+			enabled = 1.0
+		}
+
+		ch <- prometheus.MustNewConstMetric(dissectorSeenDesc, prometheus.CounterValue, float64(d.Seen), d.Name)
+		ch <- prometheus.MustNewConstMetric(dissectorRecognizedDesc, prometheus.CounterValue, float64(d.Recognized), d.Name)
+		ch <- prometheus.MustNewConstMetric(dissectorErrorsDesc, prometheus.CounterValue, float64(d.Errors), d.Name)
+		ch <- prometheus.MustNewConstMetric(dissectorSecondsDesc, prometheus.CounterValue, d.Time.Seconds(), d.Name)
+		ch <- prometheus.MustNewConstMetric(dissectorEnabledDesc, prometheus.GaugeValue, enabled, d.Name)
+	}
+}
@@ -0,0 +1,150 @@
+// Package metrics exposes the health of a pnmap sensor as Prometheus
+// metrics.
+package metrics
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+const namespace = "pnmap"
+
+// Metrics collects sensor metrics.
+type Metrics struct {
+	registry *prometheus.Registry
+
+	packetsReceived   *prometheus.CounterVec
+	packetsDropped    *prometheus.CounterVec
+	packetsOverflowed *prometheus.CounterVec
+	packetsProcessed  *prometheus.CounterVec
+	listening         *prometheus.GaugeVec
+	stateSaveDuration prometheus.Histogram
+	stateSaveErrors   prometheus.Counter
+}
+
+// New returns a new Metrics with Go runtime and process metrics registered.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+
+		packetsReceived: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "packets_received_total",
+			Help:      "Packets received per interface.",
+		}, []string{"interface"}),
+
+		packetsDropped: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "packets_dropped_total",
+			Help:      "Packets dropped by the kernel per interface because they were not read in time.",
+		}, []string{"interface"}),
+
+		packetsOverflowed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "packets_overflowed_total",
+			Help:      "Packets dropped per interface because the dissection queue was full.",
+		}, []string{"interface"}),
+
+		packetsProcessed: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "packets_processed_total",
+			Help:      "Packets processed by the dissectors, by whether they were recognized.",
+		}, []string{"result"}),
+
+		listening: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "interface_listening",
+			Help:      "Whether packets are being captured on the interface.",
+		}, []string{"interface"}),
+
+		stateSaveDuration: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "state_save_duration_seconds",
+			Help:      "Time spent saving state.",
+			Buckets:   prometheus.ExponentialBuckets(0.001, 4, 8),
+		}),
+
+		stateSaveErrors: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "state_save_errors_total",
+			Help:      "Failed attempts to save state.",
+		}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.packetsReceived,
+		m.packetsDropped,
+		m.packetsOverflowed,
+		m.packetsProcessed,
+		m.listening,
+		m.stateSaveDuration,
+		m.stateSaveErrors,
+	)
+
+	// Make both results visible before the first packet.
+	m.packetsProcessed.WithLabelValues("recognized")
+	m.packetsProcessed.WithLabelValues("unknown")
+
+	return m
+}
+
+// Attach registers metrics read from i at scrape time.
+func (m *Metrics) Attach(i *intel.Intel) {
+	m.registry.MustRegister(&intelCollector{intel: i})
+}
+
+// PacketsReceived returns the counter of packets received on iface.
+func (m *Metrics) PacketsReceived(iface string) prometheus.Counter {
+	return m.packetsReceived.WithLabelValues(iface)
+}
+
+// PacketsDropped returns the counter of packets dropped by the kernel on
+// iface.
+func (m *Metrics) PacketsDropped(iface string) prometheus.Counter {
+	return m.packetsDropped.WithLabelValues(iface)
+}
+
+// PacketsOverflowed returns the counter of packets from iface dropped
+// because the dissection queue was full.
+func (m *Metrics) PacketsOverflowed(iface string) prometheus.Counter {
+	return m.packetsOverflowed.WithLabelValues(iface)
+}
+
+// InterfaceListening returns the gauge telling whether packets are being
+// captured on iface.
+func (m *Metrics) InterfaceListening(iface string) prometheus.Gauge {
+	return m.listening.WithLabelValues(iface)
+}
+
+// PacketProcessed counts a packet processed by the dissectors.
+func (m *Metrics) PacketProcessed(recognized bool) {
+	result := "unknown"
+	if recognized {
+		result = "recognized"
+	}
+
+	m.packetsProcessed.WithLabelValues(result).Inc()
+}
+
+// StateSaved records a state save attempt. It matches the signature of
+// state.Persister.OnSave.
+func (m *Metrics) StateSaved(duration time.Duration, err error) {
+	m.stateSaveDuration.Observe(duration.Seconds())
+
+	if err != nil {
+		m.stateSaveErrors.Inc()
+	}
+}
+
+// Handler returns a handler serving the metrics.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
@@ -0,0 +1,253 @@
+package mqtt
+
+import (
+	"encoding/json"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/eclipse/paho.mqtt.golang/packets"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+type message struct {
+	topic    string
+	payload  string
+	retained bool
+}
+
+// broker is a minimal MQTT broker recording what clients publish.
+type broker struct {
+	listener net.Listener
+
+	mu        sync.Mutex
+	published []message
+	retained  map[string]string
+	will      *message
+}
+
+func newBroker(t *testing.T) *broker {
+	t.Helper()
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	b := &broker{listener: l, retained: make(map[string]string)}
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+
+			go b.serve(conn)
+		}
+	}()
+
+	t.Cleanup(func() {
+		l.Close()
+	})
+
+	return b
+}
+
+func (b *broker) serve(conn net.Conn) {
+	defer conn.Close()
+
+	for {
+		p, err := packets.ReadPacket(conn)
+		if err != nil {
+			return
+		}
+
+		switch p := p.(type) {
+		case *packets.ConnectPacket:
+			if p.WillFlag {
+				b.mu.Lock()
+				b.will = &message{topic: p.WillTopic, payload: string(p.WillMessage), retained: p.WillRetain}
+				b.mu.Unlock()
+			}
+
+			_ = packets.NewControlPacket(packets.Connack).Write(conn)
+
+		case *packets.PublishPacket:
+			b.mu.Lock()
+			b.published = append(b.published, message{topic: p.TopicName, payload: string(p.Payload), retained: p.Retain})
+			if p.Retain {
+				b.retained[p.TopicName] = string(p.Payload)
+			}
+			b.mu.Unlock()
+
+			if p.Qos > 0 {
+				ack := packets.NewControlPacket(packets.Puback).(*packets.PubackPacket)
+				ack.MessageID = p.MessageID
+				_ = ack.Write(conn)
+			}
+
+		case *packets.PingreqPacket:
+			_ = packets.NewControlPacket(packets.Pingresp).Write(conn)
+
+		case *packets.DisconnectPacket:
+			return
+		}
+	}
+}
+
+// wait waits for a message on topic matching ok.
+func (b *broker) wait(t *testing.T, topic string, ok func(m message) bool) message {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+
+	for time.Now().Before(deadline) {
+		b.mu.Lock()
+		for _, m := range b.published {
+			if m.topic == topic && ok(m) {
+				b.mu.Unlock()
+
+				return m
+			}
+		}
+		b.mu.Unlock()
+
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("nothing published to %s", topic)
+
+	return message{}
+}
+
+func anyMessage(message) bool {
+	return true
+}
+
+func TestPublisher(t *testing.T) {
+	b := newBroker(t)
+
+	now := time.Now()
+
+	i := intel.New()
+	i.Merge(
+		intel.Fact{Type: intel.NICDiscovered, MAC: "02:00:00:00:00:01", Time: now},
+		intel.Fact{Type: intel.HostnameAdded, MAC: "02:00:00:00:00:01", Source: "mdns", Value: "printer", Time: now},
+		intel.Fact{Type: intel.NICDiscovered, MAC: "02:00:00:00:00:02", Time: now.Add(-time.Hour)},
+	)
+
+	p := New(i, Config{
+		Broker:       "tcp://" + b.listener.Addr().String(),
+		ClientID:     "test",
+		Prefix:       "test",
+		Discovery:    true,
+		Track:        []string{"02:00:00:00:00:01"},
+		ConsiderHome: time.Minute,
+	})
+
+	var errs []error
+	p.OnError = func(err error) {
+		errs = append(errs, err)
+	}
+
+	p.Start()
+
+	// Retained state of the stations.
+	if m := b.wait(t, "test/status", anyMessage); !m.retained || m.payload != online {
+		t.Errorf("got status %+v", m)
+	}
+
+	m := b.wait(t, "test/nic/020000000001", anyMessage)
+
+	var nic intel.NIC
+
+	err := json.Unmarshal([]byte(m.payload), &nic)
+	if err != nil || !m.retained || nic.MAC != "02:00:00:00:00:01" || !nic.Hostnames.Contains("printer") {
+		t.Errorf("got station %+v: %v", m, err)
+	}
+
+	if m := b.wait(t, "test/nic/020000000001/presence", anyMessage); !m.retained || m.payload != home {
+		t.Errorf("got presence %+v", m)
+	}
+
+	if m := b.wait(t, "test/nic/020000000002/presence", anyMessage); !m.retained || m.payload != notHome {
+		t.Errorf("got presence %+v of a station gone for an hour", m)
+	}
+
+	// Discovery is only published for tracked stations.
+	m = b.wait(t, "homeassistant/device_tracker/pnmap_020000000001/config", anyMessage)
+
+	var config struct {
+		Name              *string `json:"name"`
+		UniqueID          string  `json:"unique_id"`
+		StateTopic        string  `json:"state_topic"`
+		AttributesTopic   string  `json:"json_attributes_topic"`
+		AvailabilityTopic string  `json:"availability_topic"`
+		PayloadHome       string  `json:"payload_home"`
+		PayloadNotHome    string  `json:"payload_not_home"`
+		SourceType        string  `json:"source_type"`
+		Device            struct {
+			Identifiers []string   `json:"identifiers"`
+			Connections [][]string `json:"connections"`
+			Name        string     `json:"name"`
+		} `json:"device"`
+	}
+
+	err = json.Unmarshal([]byte(m.payload), &config)
+	if err != nil || !m.retained {
+		t.Fatalf("got discovery %+v: %v", m, err)
+	}
+
+	if config.Name != nil || config.UniqueID != "pnmap_020000000001" ||
+		config.StateTopic != "test/nic/020000000001/presence" ||
+		config.AttributesTopic != "test/nic/020000000001" ||
+		config.AvailabilityTopic != "test/status" ||
+		config.PayloadHome != home || config.PayloadNotHome != notHome ||
+		config.SourceType != "router" ||
+		config.Device.Name != "printer" ||
+		len(config.Device.Connections) != 1 || config.Device.Connections[0][1] != "02:00:00:00:00:01" {
+		t.Errorf("got discovery %s", m.payload)
+	}
+
+	// Changes are published as events, not retained.
+	i.Merge(intel.Fact{Type: intel.IPAdded, MAC: "02:00:00:00:00:01", Source: "arp", Value: "192.0.2.1", Time: time.Now()})
+
+	m = b.wait(t, "test/events", func(m message) bool {
+		var e intel.Event
+
+		return json.Unmarshal([]byte(m.payload), &e) == nil && e.Type == intel.IPAdded && e.Value == "192.0.2.1"
+	})
+	if m.retained {
+		t.Error("event retained")
+	}
+
+	b.wait(t, "test/nic/020000000001", func(m message) bool {
+		var nic intel.NIC
+
+		return json.Unmarshal([]byte(m.payload), &nic) == nil && nic.IPs.Contains("192.0.2.1")
+	})
+
+	p.Stop()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.will == nil || b.will.topic != "test/status" || b.will.payload != offline || !b.will.retained {
+		t.Errorf("got will %+v", b.will)
+	}
+
+	if b.retained["test/status"] != offline {
+		t.Errorf("status is %s after stopping", b.retained["test/status"])
+	}
+
+	if _, found := b.retained["homeassistant/device_tracker/pnmap_020000000002/config"]; found {
+		t.Error("untracked station announced")
+	}
+
+	if len(errs) > 0 {
+		t.Errorf("errors: %v", errs)
+	}
+}
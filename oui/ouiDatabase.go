@@ -0,0 +1,29 @@
+// Package oui maps ethernet addresses to the registered owner of their
+// organizationally unique identifier.
+package oui
+
+//go:generate go run ../contrib/embed-oui.go
+
+var (
+	privates = map[byte]bool{
+		'2': true,
+		'6': true,
+		'a': true,
+		'e': true,
+	}
+)
+
+// Vendor will return the owner of a MAC address.
+func Vendor(mac string) string {
+	if len(mac) < 8 {
+		return ""
+	}
+
+	vendor := ouiToVendor[mac[0:8]]
+
+	if vendor == "" && privates[mac[1]] {
+		return "LAA (LOCALLY ADMINISTERED)"
+	}
+
+	return vendor
+}
@@ -0,0 +1,154 @@
+package remote
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+// Collector is an http.Handler merging batches from sensors into an Intel.
+type Collector struct {
+	intel *intel.Intel
+	key   []byte
+
+	// nonces are the nonces of merged batches per sensor, and when they
+	// were received.
+	mu        sync.Mutex
+	nonces    map[string]map[string]time.Time
+	lastPrune time.Time
+}
+
+// NewCollector returns a Collector merging into i. If key is not empty,
+// batches must be signed with it.
+//
+// If the sensor presented a TLS client certificate, the sensor name must
+// match its common name. Batches with a nonce already merged from the same
+// sensor are rejected.
+func NewCollector(i *intel.Intel, key []byte) *Collector {
+	return &Collector{
+		intel:  i,
+		key:    key,
+		nonces: make(map[string]map[string]time.Time),
+	}
+}
+
+// replayed returns true if sensor already sent nonce, and remembers it if
+// not.
+func (c *Collector) replayed(sensor string, nonce string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+
+	if now.Sub(c.lastPrune) > maxSkew {
+		for name, nonces := range c.nonces {
+			for n, received := range nonces {
+				if now.Sub(received) > 2*maxSkew {
+					delete(nonces, n)
+				}
+			}
+
+			if len(nonces) == 0 {
+				delete(c.nonces, name)
+			}
+		}
+
+		c.lastPrune = now
+	}
+
+	nonces, found := c.nonces[sensor]
+	if !found {
+		nonces = make(map[string]time.Time)
+		c.nonces[sensor] = nonces
+	}
+
+	if _, found := nonces[nonce]; found {
+		return true
+	}
+
+	nonces[nonce] = now
+
+	return false
+}
+
+func (c *Collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBody))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if len(c.key) > 0 && !verify(c.key, body, r.Header.Get(SignatureHeader)) {
+		http.Error(w, "bad signature", http.StatusUnauthorized)
+		return
+	}
+
+	var batch Batch
+
+	err = json.Unmarshal(body, &batch)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if batch.Sensor == "" {
+		http.Error(w, "missing sensor name", http.StatusBadRequest)
+		return
+	}
+
+	if batch.Nonce == "" {
+		http.Error(w, "missing nonce", http.StatusBadRequest)
+		return
+	}
+
+	if len(c.key) > 0 {
+		skew := time.Since(batch.Sent)
+		if skew > maxSkew || skew < -maxSkew {
+			http.Error(w, "batch too old or clock skew too large", http.StatusUnauthorized)
+			return
+		}
+	}
+
+	if r.TLS != nil && len(r.TLS.PeerCertificates) > 0 {
+		if cn := r.TLS.PeerCertificates[0].Subject.CommonName; cn != "" && cn != batch.Sensor {
+			http.Error(w, "sensor name does not match certificate", http.StatusForbidden)
+			return
+		}
+	}
+
+	if c.replayed(batch.Sensor, batch.Nonce) {
+		http.Error(w, "batch already received", http.StatusConflict)
+		return
+	}
+
+	facts := make([]intel.Fact, 0, len(batch.Records))
+	for _, rec := range batch.Records {
+		tag := batch.Sensor
+		if rec.Interface != "" {
+			tag += "/" + rec.Interface
+		}
+
+		facts = append(facts, intel.Fact{
+			Type:   rec.Type,
+			MAC:    rec.MAC,
+			Value:  rec.Value,
+			Source: rec.Source,
+			Time:   rec.Time,
+			Count:  rec.Count,
+			Sensor: tag,
+		})
+	}
+
+	c.intel.Merge(facts...)
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,235 @@
+package remote
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+func post(t *testing.T, url string, key []byte, body []byte) int {
+	t.Helper()
+
+	req, err := http.NewRequest(http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if key != nil {
+		req.Header.Set(SignatureHeader, sign(key, body))
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	resp.Body.Close()
+
+	return resp.StatusCode
+}
+
+func TestCollectorReplay(t *testing.T) {
+	key := []byte("secret")
+
+	i := intel.New()
+
+	server := httptest.NewServer(NewCollector(i, key))
+	defer server.Close()
+
+	batch := func(nonce string) []byte {
+		body, err := json.Marshal(Batch{
+			Sensor: "office",
+			Sent:   time.Now(),
+			Nonce:  nonce,
+			Records: []Record{
+				{Type: intel.NICSeen, MAC: "02:00:00:00:00:01", Time: time.Now(), Count: 10},
+			},
+		})
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		return body
+	}
+
+	first := batch("a")
+
+	if status := post(t, server.URL, key, first); status != http.StatusNoContent {
+		t.Fatalf("first batch got %d", status)
+	}
+
+	if status := post(t, server.URL, key, first); status != http.StatusConflict {
+		t.Errorf("replayed batch got %d, expected %d", status, http.StatusConflict)
+	}
+
+	if status := post(t, server.URL, key, batch("")); status != http.StatusBadRequest {
+		t.Errorf("batch without nonce got %d, expected %d", status, http.StatusBadRequest)
+	}
+
+	if status := post(t, server.URL, []byte("wrong"), batch("b")); status != http.StatusUnauthorized {
+		t.Errorf("badly signed batch got %d, expected %d", status, http.StatusUnauthorized)
+	}
+
+	if status := post(t, server.URL, key, batch("c")); status != http.StatusNoContent {
+		t.Errorf("second batch got %d", status)
+	}
+
+	nic := i.NIC("02:00:00:00:00:01")
+	if nic == nil {
+		t.Fatal("station not merged")
+	}
+
+	if nic.Seen != 20 {
+		t.Errorf("station seen %d times, expected 20", nic.Seen)
+	}
+}
+
+func TestSensorToCollector(t *testing.T) {
+	key := []byte("secret")
+
+	collected := intel.New()
+
+	server := httptest.NewServer(NewCollector(collected, key))
+	defer server.Close()
+
+	i := intel.New()
+
+	s := NewSensor(server.URL, "office", i, nil)
+	s.Key = key
+	s.Start()
+
+	i.Merge(
+		intel.Fact{Type: intel.NICDiscovered, MAC: "02:00:00:00:00:01", Time: time.Now()},
+		intel.Fact{Type: intel.IPAdded, MAC: "02:00:00:00:00:01", Source: "arp", Value: "192.0.2.1", Time: time.Now()},
+	)
+
+	err := s.Stop(5 * time.Second)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	nic := collected.NIC("02:00:00:00:00:01")
+	if nic == nil || !nic.IPs.Contains("192.0.2.1") {
+		t.Fatalf("station not collected: %+v", nic)
+	}
+}
+
+// TestSensorResend checks that a batch merged by the collector is not
+// merged again when the response is lost.
+func TestSensorResend(t *testing.T) {
+	key := []byte("secret")
+
+	collected := intel.New()
+	collector := NewCollector(collected, key)
+
+	var (
+		mu       sync.Mutex
+		nonces   []string
+		statuses []int
+	)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		var batch Batch
+		_ = json.Unmarshal(body, &batch)
+
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		rec := httptest.NewRecorder()
+		collector.ServeHTTP(rec, r)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		nonces = append(nonces, batch.Nonce)
+		statuses = append(statuses, rec.Code)
+
+		// The first response is lost after the batch was merged.
+		if len(statuses) == 1 {
+			w.WriteHeader(http.StatusBadGateway)
+
+			return
+		}
+
+		w.WriteHeader(rec.Code)
+	}))
+	defer server.Close()
+
+	i := intel.New()
+
+	s := NewSensor(server.URL, "office", i, nil)
+	s.Key = key
+	s.Interval = 10 * time.Millisecond
+	s.Start()
+
+	i.Merge(intel.Fact{Type: intel.NICSeen, MAC: "02:00:00:00:00:01", Count: 10, Time: time.Now()})
+
+	err := s.Stop(5 * time.Second)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+
+	if len(nonces) != 2 || nonces[0] != nonces[1] {
+		t.Fatalf("sent nonces %v, expected the same batch twice", nonces)
+	}
+
+	if statuses[1] != http.StatusConflict {
+		t.Errorf("resent batch got %d, expected %d", statuses[1], http.StatusConflict)
+	}
+
+	if nic := collected.NIC("02:00:00:00:00:01"); nic == nil || nic.Seen != 10 {
+		t.Errorf("collected %+v, expected the station seen 10 times", nic)
+	}
+
+	if s.Buffered() != 0 {
+		t.Errorf("%d records left after the batch was acknowledged", s.Buffered())
+	}
+}
+
+// TestSensorStopRetries checks that Stop keeps trying to send the buffer
+// until the timeout. Nothing is sent before Stop, as the interval is long.
+func TestSensorStopRetries(t *testing.T) {
+	collected := intel.New()
+	collector := NewCollector(collected, nil)
+
+	var failures atomic.Int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures.Add(1) <= 3 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+
+			return
+		}
+
+		collector.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	i := intel.New()
+
+	s := NewSensor(server.URL, "office", i, nil)
+	s.Interval = time.Hour
+	s.Start()
+
+	i.Merge(intel.Fact{Type: intel.IPAdded, MAC: "02:00:00:00:00:01", Source: "arp", Value: "192.0.2.1", Time: time.Now()})
+
+	err := s.Stop(5 * time.Second)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if nic := collected.NIC("02:00:00:00:00:01"); nic == nil || !nic.IPs.Contains("192.0.2.1") {
+		t.Errorf("station not collected: %+v", nic)
+	}
+}
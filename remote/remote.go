@@ -0,0 +1,77 @@
+// Package remote streams observations from sensors to a collector merging
+// them into one inventory.
+//
+// Sensors POST batches of records as JSON to the collector. The link is
+// authenticated with mutual TLS, a shared key, or both. With a shared key,
+// every batch is signed with HMAC-SHA256 in the X-Pnmap-Signature header.
+// Every batch carries a random nonce, and the collector rejects batches
+// it has already merged.
+package remote
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+const (
+	// SignatureHeader holds the hex encoded HMAC-SHA256 of the body.
+	SignatureHeader = "X-Pnmap-Signature"
+
+	// maxSkew is how old or new a signed batch may be. Nonces are
+	// remembered for twice as long, which covers every batch accepted
+	// within the window.
+	maxSkew = 5 * time.Minute
+
+	// maxBody is the largest batch accepted by a collector.
+	maxBody = 32 << 20
+)
+
+// Batch is a set of records sent by a sensor.
+type Batch struct {
+	Sensor  string    `json:"sensor"`
+	Sent    time.Time `json:"sent"`
+	Nonce   string    `json:"nonce"`
+	Records []Record  `json:"records"`
+}
+
+// Record is a single observation. It mirrors intel.Event.
+type Record struct {
+	Type      intel.EventType `json:"type"`
+	MAC       string          `json:"mac"`
+	Value     string          `json:"value,omitempty"`
+	Source    string          `json:"source,omitempty"`
+	Time      time.Time       `json:"time"`
+	Count     int             `json:"count,omitempty"`
+	Interface string          `json:"interface,omitempty"`
+}
+
+func nonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+
+	return hex.EncodeToString(b)
+}
+
+func sign(key []byte, body []byte) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func verify(key []byte, body []byte, signature string) bool {
+	expected, err := hex.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
@@ -0,0 +1,419 @@
+package remote
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+const (
+	batchSize  = 5000
+	maxBackoff = time.Minute
+
+	// stopBackoff is the first delay before Stop retries.
+	stopBackoff = 100 * time.Millisecond
+)
+
+// Sensor streams events from an Intel to a collector. Records are buffered
+// in memory while the collector is unreachable, and the oldest records are
+// dropped when the buffer is full.
+type Sensor struct {
+	url    string
+	name   string
+	intel  *intel.Intel
+	sub    *intel.Subscription
+	client *http.Client
+
+	// Key signs batches if set.
+	Key []byte
+
+	// Interval is how often batches are sent. It defaults to one second.
+	Interval time.Duration
+
+	// MaxBuffer is the maximum number of buffered records. It defaults to
+	// 100000.
+	MaxBuffer int
+
+	// OnError is called when sending fails, if set.
+	OnError func(err error)
+
+	mu      sync.Mutex
+	buffer  []Record
+	dropped uint64
+
+	// seen aggregates NICSeen events per station and interface until the
+	// next batch.
+	seen map[string]*Record
+
+	ifnames map[int]string
+
+	// pending is the batch being sent. It is resent unchanged until the
+	// collector acknowledges it, so a batch merged without the response
+	// reaching the sensor is rejected as a replay instead of merged again.
+	// It is only used by the goroutine sending.
+	pending *pendingBatch
+
+	stop      chan struct{}
+	done      chan struct{}
+	collected chan struct{}
+}
+
+// pendingBatch is a batch sent but not acknowledged by the collector.
+type pendingBatch struct {
+	body    []byte
+	sent    time.Time
+	records []Record
+}
+
+// NewSensor returns a Sensor named name sending events from i to the
+// collector at url using client. A nil client uses http.DefaultClient.
+func NewSensor(url string, name string, i *intel.Intel, client *http.Client) *Sensor {
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &Sensor{
+		url:       url,
+		name:      name,
+		intel:     i,
+		client:    client,
+		Interval:  time.Second,
+		MaxBuffer: 100000,
+		seen:      make(map[string]*Record),
+		ifnames:   make(map[int]string),
+		stop:      make(chan struct{}),
+		done:      make(chan struct{}),
+		collected: make(chan struct{}),
+	}
+}
+
+// Buffered returns the number of records waiting to be sent.
+func (s *Sensor) Buffered() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.buffer) + len(s.seen)
+}
+
+// Dropped returns the number of records dropped because the buffer was full.
+func (s *Sensor) Dropped() uint64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.dropped
+}
+
+// Start starts streaming in the background.
+func (s *Sensor) Start() {
+	s.sub = s.intel.Subscribe(10000)
+
+	go s.collect()
+	go s.send()
+}
+
+// Stop stops streaming and tries to send what is buffered within timeout,
+// retrying with backoff.
+func (s *Sensor) Stop(timeout time.Duration) error {
+	// Packets not yet published are sent too.
+	s.intel.Flush()
+
+	s.sub.Close()
+	<-s.collected
+
+	close(s.stop)
+	<-s.done
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	backoff := stopBackoff
+
+	for s.Buffered() > 0 {
+		err := s.sendBatch(ctx)
+		if err == nil {
+			backoff = stopBackoff
+
+			continue
+		}
+
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return err
+		}
+
+		backoff = min(backoff*2, maxBackoff)
+	}
+
+	return nil
+}
+
+func (s *Sensor) ifname(index int) string {
+	if index == 0 {
+		return ""
+	}
+
+	name, found := s.ifnames[index]
+	if !found {
+		intf, err := net.InterfaceByIndex(index)
+		if err == nil {
+			name = intf.Name
+		}
+
+		s.ifnames[index] = name
+	}
+
+	return name
+}
+
+// collect moves events from the subscription to the buffer.
+func (s *Sensor) collect() {
+	defer close(s.collected)
+
+	var dropped uint64
+
+	for e := range s.sub.C {
+		iface := s.ifname(e.Interface)
+
+		s.mu.Lock()
+
+		// If events were lost, resend everything known.
+		if d := s.sub.Dropped(); d != dropped {
+			dropped = d
+			s.add(snapshot(s.intel.NICs())...)
+		}
+
+		switch e.Type {
+		case intel.NICSeen:
+			key := e.MAC + "\x00" + iface
+
+			rec, found := s.seen[key]
+			if !found {
+				rec = &Record{Type: intel.NICSeen, MAC: e.MAC, Interface: iface}
+				s.seen[key] = rec
+			}
+
+			rec.Count += e.Count
+			if e.Time.After(rec.Time) {
+				rec.Time = e.Time
+			}
+
+		case intel.NICStale:
+			// Staleness is decided by the collector.
+
+		default:
+			s.add(Record{
+				Type:      e.Type,
+				MAC:       e.MAC,
+				Value:     e.Value,
+				Source:    e.Source,
+				Time:      e.Time,
+				Interface: iface,
+			})
+		}
+
+		s.mu.Unlock()
+	}
+}
+
+// add appends records to the buffer. It must be called with the lock held.
+func (s *Sensor) add(records ...Record) {
+	s.buffer = append(s.buffer, records...)
+
+	if over := len(s.buffer) - s.MaxBuffer; over > 0 {
+		// Drop at least a tenth to avoid moving the buffer for every
+		// record.
+		if over < s.MaxBuffer/10 {
+			over = s.MaxBuffer / 10
+		}
+
+		s.dropped += uint64(over)
+		s.buffer = append(s.buffer[:0], s.buffer[over:]...)
+	}
+}
+
+// snapshot returns records describing nics.
+func snapshot(nics intel.NICCollection) []Record {
+	var records []Record
+
+	for _, nic := range nics {
+		records = append(records,
+			Record{Type: intel.NICDiscovered, MAC: nic.MAC, Time: nic.FirstSeen},
+			Record{Type: intel.NICSeen, MAC: nic.MAC, Time: nic.LastSeen},
+		)
+
+		for typ, observations := range map[intel.EventType]intel.Observations{
+			intel.IPAdded:          nic.IPs,
+			intel.HostnameAdded:    nic.Hostnames,
+			intel.UserAgentAdded:   nic.UserAgents,
+			intel.VendorAdded:      nic.Vendor,
+			intel.ApplicationAdded: nic.Applications,
+		} {
+			for _, o := range observations {
+				records = append(records, Record{
+					Type:   typ,
+					MAC:    nic.MAC,
+					Value:  o.Value,
+					Source: o.Source,
+					Time:   o.LastSeen,
+				})
+			}
+		}
+	}
+
+	return records
+}
+
+// send sends batches every interval until stopped, backing off while the
+// collector is unreachable.
+func (s *Sensor) send() {
+	defer close(s.done)
+
+	wait := s.Interval
+	backoff := s.Interval
+
+	for {
+		select {
+		case <-time.After(wait):
+		case <-s.stop:
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		err := s.sendBatch(ctx)
+		cancel()
+
+		if err != nil {
+			if s.OnError != nil {
+				s.OnError(err)
+			}
+
+			backoff *= 2
+			if backoff > maxBackoff {
+				backoff = maxBackoff
+			}
+
+			wait = backoff
+
+			continue
+		}
+
+		backoff = s.Interval
+		wait = s.Interval
+
+		// Keep sending while there is a backlog.
+		if s.Buffered() > 0 {
+			wait = 0
+		}
+	}
+}
+
+// sendBatch sends up to batchSize records. Records are only removed from
+// the buffer when the collector acknowledged them.
+func (s *Sensor) sendBatch(ctx context.Context) error {
+	// A signed batch is rejected once older than maxSkew, so one the
+	// collector has not acknowledged for half that is built again.
+	if s.pending != nil && len(s.Key) > 0 && time.Since(s.pending.sent) > maxSkew/2 {
+		s.pending = nil
+	}
+
+	if s.pending == nil {
+		batch, err := s.nextBatch()
+		if batch == nil || err != nil {
+			return err
+		}
+
+		s.pending = batch
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.url, bytes.NewReader(s.pending.body))
+	if err != nil {
+		return err
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+	if len(s.Key) > 0 {
+		req.Header.Set(SignatureHeader, sign(s.Key, s.pending.body))
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	// A conflict means the batch was merged before, but the response was
+	// lost.
+	if (resp.StatusCode < 200 || resp.StatusCode > 299) && resp.StatusCode != http.StatusConflict {
+		return fmt.Errorf("%s: %s", s.url, resp.Status)
+	}
+
+	s.mu.Lock()
+	s.removeSent(s.pending.records)
+	s.mu.Unlock()
+
+	s.pending = nil
+
+	return nil
+}
+
+// nextBatch returns a batch of up to batchSize buffered records, or nil if
+// nothing is buffered.
+func (s *Sensor) nextBatch() (*pendingBatch, error) {
+	s.mu.Lock()
+
+	for _, rec := range s.seen {
+		s.add(*rec)
+	}
+	s.seen = make(map[string]*Record)
+
+	n := len(s.buffer)
+	if n > batchSize {
+		n = batchSize
+	}
+
+	records := make([]Record, n)
+	copy(records, s.buffer)
+
+	s.mu.Unlock()
+
+	if n == 0 {
+		return nil, nil
+	}
+
+	sent := time.Now()
+
+	body, err := json.Marshal(Batch{
+		Sensor:  s.name,
+		Sent:    sent,
+		Nonce:   nonce(),
+		Records: records,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &pendingBatch{body: body, sent: sent, records: records}, nil
+}
+
+// removeSent removes sent records from the front of the buffer. Records
+// dropped while sending may already be gone. It must be called with the
+// lock held.
+func (s *Sensor) removeSent(sent []Record) {
+	// The buffer only shrinks from the front, so the sent records are
+	// either still first, or were partly dropped.
+	for skip := 0; skip < len(sent); skip++ {
+		rest := sent[skip:]
+		if len(s.buffer) >= len(rest) && s.buffer[0] == rest[0] {
+			s.buffer = append(s.buffer[:0], s.buffer[len(rest):]...)
+			return
+		}
+	}
+}
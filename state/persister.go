@@ -0,0 +1,113 @@
+package state
+
+import (
+	"sync"
+	"time"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+// Persister saves the stations of an Intel at an interval, and when
+// stopped. Nothing is written if nothing changed since the last save.
+type Persister struct {
+	path     string
+	intel    *intel.Intel
+	interval time.Duration
+
+	// Sensor and Network are saved along with the stations.
+	Sensor  Sensor
+	Network Network
+
+	// OnSave is called after every save attempt, if set.
+	OnSave func(duration time.Duration, err error)
+
+	mu        sync.Mutex
+	saved     uint64
+	stop      chan struct{}
+	done      chan struct{}
+	startOnce sync.Once
+	stopOnce  sync.Once
+}
+
+// NewPersister returns a Persister saving the stations of i to path every
+// interval.
+func NewPersister(path string, i *intel.Intel, interval time.Duration) *Persister {
+	return &Persister{
+		path:     path,
+		intel:    i,
+		interval: interval,
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+}
+
+// Start starts saving in the background.
+func (p *Persister) Start() {
+	p.startOnce.Do(func() {
+		go p.run()
+	})
+}
+
+func (p *Persister) run() {
+	defer close(p.done)
+
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			_ = p.Flush()
+
+		case <-p.stop:
+			return
+		}
+	}
+}
+
+// Flush saves the stations now if anything changed since the last save.
+func (p *Persister) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	generation := p.intel.Generation()
+	if generation == p.saved {
+		return nil
+	}
+
+	start := time.Now()
+
+	err := Save(p.path, &File{
+		Sensor:  p.Sensor,
+		Network: p.Network,
+		NICs:    p.intel.NICs(),
+	})
+	if err == nil {
+		p.saved = generation
+	}
+
+	if p.OnSave != nil {
+		p.OnSave(time.Since(start), err)
+	}
+
+	return err
+}
+
+// Stop stops background saving and saves a final time.
+func (p *Persister) Stop() error {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+
+	p.startOnce.Do(func() {
+		close(p.done)
+	})
+
+	<-p.done
+
+	// Stations seen since their last NICSeen event have not advanced the
+	// generation yet.
+	p.intel.Flush()
+
+	return p.Flush()
+}
@@ -0,0 +1,75 @@
+package state
+
+import (
+	"net"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/google/gopacket"
+	"github.com/google/gopacket/layers"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+// TestPersisterStop checks that packets from stations seen since their
+// last NICSeen event are saved when stopping.
+func TestPersisterStop(t *testing.T) {
+	mac, _ := net.ParseMAC("02:00:00:00:00:01")
+
+	buf := gopacket.NewSerializeBuffer()
+
+	err := gopacket.SerializeLayers(buf, gopacket.SerializeOptions{FixLengths: true},
+		&layers.Ethernet{SrcMAC: mac, DstMAC: layers.EthernetBroadcast, EthernetType: layers.EthernetTypeARP},
+		&layers.ARP{
+			AddrType:          layers.LinkTypeEthernet,
+			Protocol:          layers.EthernetTypeIPv4,
+			HwAddressSize:     6,
+			ProtAddressSize:   4,
+			Operation:         layers.ARPRequest,
+			SourceHwAddress:   mac,
+			SourceProtAddress: []byte{192, 0, 2, 1},
+			DstHwAddress:      make([]byte, 6),
+			DstProtAddress:    []byte{192, 0, 2, 2},
+		},
+	)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	i := intel.New()
+	d := intel.NewDecoder()
+	start := time.Now()
+
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	p := NewPersister(path, i, time.Hour)
+	p.Start()
+
+	i.NewFrame(d, buf.Bytes(), gopacket.CaptureInfo{Timestamp: start})
+
+	err = p.Flush()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	// Within the seen interval, so not published yet.
+	for n := 1; n < 5; n++ {
+		i.NewFrame(d, buf.Bytes(), gopacket.CaptureInfo{Timestamp: start.Add(time.Duration(n) * time.Millisecond)})
+	}
+
+	err = p.Stop()
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	f, err := Load(path)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	nic := f.NICs[mac.String()]
+	if nic == nil || nic.Seen != 5 {
+		t.Fatalf("saved %+v, expected the station seen 5 times", nic)
+	}
+}
@@ -0,0 +1,231 @@
+// Package state persists collected stations to disk.
+package state
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+// Version is the version of the state file format written by Save.
+const Version = 2
+
+// Sensor describes the host that collected the stations.
+type Sensor struct {
+	Hostname   string   `json:"Hostname"`
+	Interfaces []string `json:"Interfaces"`
+}
+
+// Network identifies the network the stations were collected on.
+type Network struct {
+	Gateway    string `json:"Gateway"`
+	GatewayMAC string `json:"GatewayMAC"`
+}
+
+// File is the content of a state file.
+type File struct {
+	Version int                 `json:"Version"`
+	Saved   time.Time           `json:"Saved"`
+	Sensor  Sensor              `json:"Sensor"`
+	Network Network             `json:"Network"`
+	NICs    intel.NICCollection `json:"NICs"`
+}
+
+// LoadError is returned by Load and Read when a state file cannot be
+// decoded. Load copies the file to Backup before returning.
+type LoadError struct {
+	Path   string
+	Backup string
+	Err    error
+}
+
+func (e *LoadError) Error() string {
+	if e.Backup == "" {
+		return fmt.Sprintf("state file %s is unreadable: %s", e.Path, e.Err)
+	}
+
+	return fmt.Sprintf("state file %s is unreadable (backup saved to %s): %s", e.Path, e.Backup, e.Err)
+}
+
+func (e *LoadError) Unwrap() error {
+	return e.Err
+}
+
+// migrations upgrade a state file from the version used as key to the next
+// version.
+var migrations = map[int]func(data []byte) ([]byte, error){
+	1: migrateV1,
+}
+
+// migrateV1 wraps the bare map of stations used before versioning.
+func migrateV1(data []byte) ([]byte, error) {
+	var nics json.RawMessage
+
+	err := json.Unmarshal(data, &nics)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(struct {
+		Version int             `json:"Version"`
+		NICs    json.RawMessage `json:"NICs"`
+	}{2, nics})
+}
+
+// version returns the version of the state file in data. Files without a
+// version are version 1.
+func version(data []byte) (int, error) {
+	var fields map[string]json.RawMessage
+
+	err := json.Unmarshal(data, &fields)
+	if err != nil {
+		return 0, err
+	}
+
+	raw, found := fields["Version"]
+	if !found {
+		return 1, nil
+	}
+
+	var v int
+
+	err = json.Unmarshal(raw, &v)
+	if err != nil {
+		return 0, fmt.Errorf("invalid version: %w", err)
+	}
+
+	return v, nil
+}
+
+// decode decodes a state file of any known version.
+func decode(data []byte) (*File, error) {
+	v, err := version(data)
+	if err != nil {
+		return nil, err
+	}
+
+	for v != Version {
+		migrate, found := migrations[v]
+		if !found {
+			return nil, fmt.Errorf("unknown version %d", v)
+		}
+
+		data, err = migrate(data)
+		if err != nil {
+			return nil, fmt.Errorf("migrating from version %d: %w", v, err)
+		}
+
+		v++
+	}
+
+	f := &File{}
+
+	err = json.Unmarshal(data, f)
+	if err != nil {
+		return nil, err
+	}
+
+	if f.NICs == nil {
+		f.NICs = make(intel.NICCollection)
+	}
+
+	return f, nil
+}
+
+// Load reads a state file from path. A missing file results in an empty
+// state. If the file cannot be decoded, it is backed up and a *LoadError is
+// returned.
+func Load(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return &File{Version: Version, NICs: make(intel.NICCollection)}, nil
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := decode(data)
+	if err != nil {
+		lerr := &LoadError{Path: path, Err: err}
+
+		backup := fmt.Sprintf("%s.%s.bak", path, time.Now().UTC().Format("20060102T150405Z"))
+		if writeFile(backup, data) == nil {
+			lerr.Backup = backup
+		}
+
+		return nil, lerr
+	}
+
+	return f, nil
+}
+
+// Read reads a state file from path without side effects. Unlike Load, a
+// missing file is an error, and unreadable files are not backed up.
+func Read(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := decode(data)
+	if err != nil {
+		return nil, &LoadError{Path: path, Err: err}
+	}
+
+	return f, nil
+}
+
+// Save writes f to path with the current version and time. The state is
+// written to a temporary file that replaces path when complete, so path is
+// never left truncated.
+func Save(path string, f *File) error {
+	f.Version = Version
+	f.Saved = time.Now()
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+
+	return writeFile(path, data)
+}
+
+// writeFile atomically replaces path with data.
+func writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+
+	err := os.MkdirAll(dir, 0700)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, "."+filepath.Base(path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+
+	// Does nothing after a successful rename.
+	defer os.Remove(f.Name())
+
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
@@ -0,0 +1,109 @@
+package state
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoad(t *testing.T) {
+	v1, err := os.ReadFile("testdata/v1.json")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	cases := []struct {
+		name     string
+		data     []byte
+		stations int
+		mac      string
+		fails    bool
+	}{
+		{"v1", v1, 2, "00:11:32:aa:bb:cc", false},
+		{"v2", []byte(`{"Version":2,"NICs":{"02:00:00:00:00:01":{"MAC":"02:00:00:00:00:01"}}}`), 1, "02:00:00:00:00:01", false},
+		{"future", []byte(`{"Version":3,"NICs":{}}`), 0, "", true},
+		{"truncated", v1[:len(v1)/2], 0, "", true},
+		{"invalid version", []byte(`{"Version":"2"}`), 0, "", true},
+	}
+
+	for _, c := range cases {
+		t.Run(c.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "state.json")
+
+			err := os.WriteFile(path, c.data, 0o600)
+			if err != nil {
+				t.Fatalf("%s", err)
+			}
+
+			f, err := Load(path)
+
+			backups, _ := filepath.Glob(path + ".*.bak")
+
+			if !c.fails {
+				if err != nil {
+					t.Fatalf("%s", err)
+				}
+
+				if f.Version != Version || len(f.NICs) != c.stations {
+					t.Errorf("loaded version %d with %d stations, expected version %d with %d", f.Version, len(f.NICs), Version, c.stations)
+				}
+
+				if nic := f.NICs[c.mac]; nic == nil || nic.MAC != c.mac {
+					t.Errorf("station %s not loaded: %+v", c.mac, nic)
+				}
+
+				if len(backups) > 0 {
+					t.Errorf("backups made of a good file: %v", backups)
+				}
+
+				return
+			}
+
+			var lerr *LoadError
+			if !errors.As(err, &lerr) {
+				t.Fatalf("got %v, expected a *LoadError", err)
+			}
+
+			if len(backups) != 1 || lerr.Backup != backups[0] {
+				t.Fatalf("backups %v, expected %s", backups, lerr.Backup)
+			}
+
+			backup, err := os.ReadFile(lerr.Backup)
+			if err != nil || string(backup) != string(c.data) {
+				t.Errorf("backup differs from the file: %v", err)
+			}
+
+			// Read has no side effects.
+			os.Remove(lerr.Backup)
+
+			_, err = Read(path)
+			if !errors.As(err, &lerr) || lerr.Backup != "" {
+				t.Errorf("got %v from Read, expected a *LoadError without backup", err)
+			}
+
+			if backups, _ := filepath.Glob(path + ".*.bak"); len(backups) > 0 {
+				t.Errorf("Read made backups: %v", backups)
+			}
+		})
+	}
+}
+
+func TestLoadMissing(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state.json")
+
+	f, err := Load(path)
+	if err != nil || len(f.NICs) != 0 {
+		t.Fatalf("got %v, %v, expected an empty state", f, err)
+	}
+
+	_, err = Read(path)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("got %v from Read, expected a missing file", err)
+	}
+
+	if _, err := os.Stat(path); !errors.Is(err, os.ErrNotExist) {
+		t.Errorf("loading created %s", path)
+	}
+}
@@ -0,0 +1,272 @@
+package syslog
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/abrander/pnmap/intel"
+	"github.com/abrander/pnmap/oui"
+)
+
+// Formats lists the supported payload formats.
+var Formats = []string{"rfc5424", "cef", "leef"}
+
+const (
+	deviceVendor  = "pnmap"
+	deviceProduct = "pnmap"
+	deviceVersion = "1.0"
+
+	// sdID is the ID of the structured data element. 32473 is the private
+	// enterprise number reserved for documentation and examples.
+	sdID = "pnmap@32473"
+)
+
+var eventNames = map[intel.EventType]string{
+	intel.NICDiscovered:    "Station discovered",
+	intel.NICSeen:          "Station seen",
+	intel.NICStale:         "Station stale",
+	intel.IPAdded:          "IP address observed",
+	intel.HostnameAdded:    "Hostname observed",
+	intel.UserAgentAdded:   "User agent observed",
+	intel.VendorAdded:      "Vendor observed",
+	intel.ApplicationAdded: "Application observed",
+}
+
+// fields are the values of an event mapped from its station.
+type fields struct {
+	event       string
+	name        string
+	mac         string
+	ipv4        string
+	ipv6        string
+	hostname    string
+	userAgent   string
+	vendor      string
+	value       string
+	source      string
+	application string
+	time        time.Time
+}
+
+// latest returns the most recently seen value of o accepted by keep, or ""
+// if none. preferred is returned if it is in o and accepted.
+func latest(o intel.Observations, preferred string, keep func(string) bool) string {
+	if preferred != "" && o.Contains(preferred) && keep(preferred) {
+		return preferred
+	}
+
+	var best *intel.Observation
+	for n := range o {
+		if keep(o[n].Value) && (best == nil || o[n].LastSeen.After(best.LastSeen)) {
+			best = &o[n]
+		}
+	}
+
+	if best == nil {
+		return ""
+	}
+
+	return best.Value
+}
+
+func isIPv4(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() != nil
+}
+
+func isIPv6(s string) bool {
+	ip := net.ParseIP(s)
+	return ip != nil && ip.To4() == nil
+}
+
+func anyValue(string) bool {
+	return true
+}
+
+func eventFields(e intel.Event) fields {
+	f := fields{
+		event:  e.Type.String(),
+		name:   eventNames[e.Type],
+		mac:    e.MAC,
+		value:  e.Value,
+		source: e.Source,
+		vendor: oui.Vendor(e.MAC),
+		time:   e.Time,
+	}
+
+	nic := e.NIC
+	if nic == nil {
+		return f
+	}
+
+	f.ipv4 = latest(nic.IPs, e.Value, isIPv4)
+	f.ipv6 = latest(nic.IPs, e.Value, isIPv6)
+	f.hostname = latest(nic.Hostnames, e.Value, anyValue)
+	f.userAgent = latest(nic.UserAgents, e.Value, anyValue)
+	f.application = strings.Join(nic.Applications.Values(), ",")
+
+	if f.vendor == "" {
+		f.vendor = latest(nic.Vendor, e.Value, anyValue)
+	}
+
+	return f
+}
+
+// severity returns the syslog severity and CEF severity of e.
+func severity(e intel.Event) (Severity, int) {
+	switch e.Type {
+	case intel.NICDiscovered:
+		return Notice, 5
+	case intel.NICStale:
+		return Warning, 4
+	}
+
+	return Info, 3
+}
+
+// sdEscape escapes a structured data parameter value.
+var sdEscape = strings.NewReplacer(`\`, `\\`, `"`, `\"`, `]`, `\]`)
+
+// rfc5424 returns the structured data and message of e.
+func (f *fields) rfc5424() (string, string) {
+	var sd strings.Builder
+
+	sd.WriteString("[" + sdID)
+	for _, p := range [][2]string{
+		{"event", f.event},
+		{"mac", f.mac},
+		{"value", f.value},
+		{"source", f.source},
+		{"ip", f.ipv4},
+		{"ipv6", f.ipv6},
+		{"hostname", f.hostname},
+		{"vendor", f.vendor},
+		{"applications", f.application},
+	} {
+		if p[1] != "" {
+			fmt.Fprintf(&sd, ` %s="%s"`, p[0], sdEscape.Replace(p[1]))
+		}
+	}
+	sd.WriteString("]")
+
+	msg := f.name + " " + f.mac
+	if f.value != "" {
+		msg += ": " + f.value
+	}
+
+	return sd.String(), msg
+}
+
+var (
+	cefHeaderEscape    = strings.NewReplacer(`\`, `\\`, `|`, `\|`)
+	cefExtensionEscape = strings.NewReplacer(`\`, `\\`, `=`, `\=`, "\r", `\r`, "\n", `\n`)
+)
+
+// cef returns e as an ArcSight Common Event Format message.
+func (f *fields) cef(severity int) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "CEF:0|%s|%s|%s|%s|%s|%d|",
+		cefHeaderEscape.Replace(deviceVendor),
+		cefHeaderEscape.Replace(deviceProduct),
+		cefHeaderEscape.Replace(deviceVersion),
+		cefHeaderEscape.Replace(f.event),
+		cefHeaderEscape.Replace(f.name),
+		severity,
+	)
+
+	ext := [][2]string{
+		{"rt", strconv.FormatInt(f.time.UnixMilli(), 10)},
+		{"cat", f.event},
+		{"smac", f.mac},
+		{"src", f.ipv4},
+		{"shost", f.hostname},
+		{"requestClientApplication", f.userAgent},
+	}
+
+	if f.ipv6 != "" {
+		ext = append(ext, [2]string{"c6a2", f.ipv6}, [2]string{"c6a2Label", "Source IPv6 Address"})
+	}
+
+	for n, p := range [][2]string{
+		{"Value", f.value},
+		{"Dissector", f.source},
+		{"Vendor", f.vendor},
+		{"Applications", f.application},
+	} {
+		if p[1] != "" {
+			key := fmt.Sprintf("cs%d", n+1)
+			ext = append(ext, [2]string{key, p[1]}, [2]string{key + "Label", p[0]})
+		}
+	}
+
+	first := true
+	for _, p := range ext {
+		if p[1] == "" {
+			continue
+		}
+
+		if !first {
+			b.WriteByte(' ')
+		}
+		first = false
+
+		b.WriteString(p[0] + "=" + cefExtensionEscape.Replace(p[1]))
+	}
+
+	return b.String()
+}
+
+// leefEscape removes the attribute delimiter and line breaks from values.
+var leefEscape = strings.NewReplacer("\t", " ", "\r", " ", "\n", " ")
+
+// leef returns e as an IBM Log Event Extended Format 2.0 message.
+func (f *fields) leef(severity int) string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "LEEF:2.0|%s|%s|%s|%s|",
+		deviceVendor,
+		deviceProduct,
+		deviceVersion,
+		f.event,
+	)
+
+	src := f.ipv4
+	if src == "" {
+		src = f.ipv6
+	}
+
+	attrs := [][2]string{
+		{"cat", f.event},
+		{"devTime", f.time.UTC().Format("Jan 02 2006 15:04:05.000 MST")},
+		{"devTimeFormat", "MMM dd yyyy HH:mm:ss.SSS z"},
+		{"sev", strconv.Itoa(severity)},
+		{"srcMAC", f.mac},
+		{"src", src},
+		{"identHostName", f.hostname},
+		{"userAgent", f.userAgent},
+		{"value", f.value},
+		{"dissector", f.source},
+		{"vendor", f.vendor},
+		{"applications", f.application},
+	}
+
+	first := true
+	for _, p := range attrs {
+		if p[1] == "" {
+			continue
+		}
+
+		if !first {
+			b.WriteByte('\t')
+		}
+		first = false
+
+		b.WriteString(p[0] + "=" + leefEscape.Replace(p[1]))
+	}
+
+	return b.String()
+}
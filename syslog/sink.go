@@ -0,0 +1,61 @@
+package syslog
+
+import (
+	"fmt"
+
+	"github.com/abrander/pnmap/intel"
+)
+
+// Sink writes events from a subscription to a Writer.
+type Sink struct {
+	w      *Writer
+	format string
+
+	// OnError is called for messages that could not be sent, if set.
+	OnError func(err error)
+}
+
+// NewSink returns a Sink writing to w in format, which is one of Formats.
+func NewSink(w *Writer, format string) (*Sink, error) {
+	switch format {
+	case "rfc5424", "cef", "leef":
+	default:
+		return nil, fmt.Errorf("unknown syslog format '%s'", format)
+	}
+
+	return &Sink{w: w, format: format}, nil
+}
+
+// Run writes discovery and change events from sub until it is closed.
+// NICSeen events are skipped.
+func (s *Sink) Run(sub *intel.Subscription) {
+	for e := range sub.C {
+		if e.Type == intel.NICSeen {
+			continue
+		}
+
+		err := s.Write(e)
+		if err != nil && s.OnError != nil {
+			s.OnError(err)
+		}
+	}
+}
+
+// Write sends a single event.
+func (s *Sink) Write(e intel.Event) error {
+	f := eventFields(e)
+	sev, cefSeverity := severity(e)
+
+	var sd, msg string
+
+	switch s.format {
+	case "cef":
+		msg = f.cef(cefSeverity)
+	case "leef":
+		msg = f.leef(cefSeverity)
+	default:
+		sd, msg = f.rfc5424()
+	}
+
+	return s.w.Write(sev, e.Time, f.event, sd, msg)
+}
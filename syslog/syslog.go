@@ -0,0 +1,246 @@
+// Package syslog sends events from an Intel to a syslog server as RFC 5424
+// messages, optionally with an ArcSight CEF or IBM LEEF payload.
+package syslog
+
+import (
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Facility is a syslog facility.
+type Facility int
+
+var facilityNames = map[string]Facility{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// ParseFacility returns the facility named name, like "local0".
+func ParseFacility(name string) (Facility, error) {
+	f, found := facilityNames[strings.ToLower(name)]
+	if !found {
+		return 0, fmt.Errorf("unknown syslog facility '%s'", name)
+	}
+
+	return f, nil
+}
+
+// Severity is a syslog severity.
+type Severity int
+
+// Severities used by pnmap.
+const (
+	Warning Severity = 4
+	Notice  Severity = 5
+	Info    Severity = 6
+)
+
+// Writer writes RFC 5424 messages to a syslog server. Messages are sent
+// one per datagram over UDP and unix datagram sockets, and with octet
+// counting framing (RFC 6587) over streams. The connection is redialed on
+// the next write after a failed write.
+type Writer struct {
+	mu   sync.Mutex
+	conn net.Conn
+
+	network   string
+	address   string
+	tlsConfig *tls.Config
+	stream    bool
+
+	// Facility is used for all messages. It defaults to local0.
+	Facility Facility
+
+	// Hostname and AppName fill the header. They default to the hostname
+	// and "pnmap".
+	Hostname string
+	AppName  string
+
+	procID string
+}
+
+// Dial connects to the syslog server at target, which is a URL like
+// udp://host:514, tcp://host:601, tls://host:6514 or unix:///dev/log. The
+// port defaults to 514 for UDP and TCP and 6514 for TLS. tlsConfig is
+// used for tls:// and may be nil.
+func Dial(target string, tlsConfig *tls.Config) (*Writer, error) {
+	u, err := url.Parse(target)
+	if err != nil {
+		return nil, err
+	}
+
+	hostname, _ := os.Hostname()
+
+	w := &Writer{
+		Facility:  16,
+		Hostname:  hostname,
+		AppName:   "pnmap",
+		procID:    strconv.Itoa(os.Getpid()),
+		tlsConfig: tlsConfig,
+	}
+
+	switch u.Scheme {
+	case "udp":
+		w.network, w.address = "udp", withPort(u.Host, "514")
+	case "tcp":
+		w.network, w.address, w.stream = "tcp", withPort(u.Host, "514"), true
+	case "tls":
+		w.network, w.address, w.stream = "tls", withPort(u.Host, "6514"), true
+	case "unix":
+		w.network, w.address = "unix", u.Path
+	default:
+		return nil, fmt.Errorf("unsupported syslog scheme '%s'", u.Scheme)
+	}
+
+	err = w.dial()
+	if err != nil {
+		return nil, err
+	}
+
+	return w, nil
+}
+
+func withPort(host string, port string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+
+	return net.JoinHostPort(host, port)
+}
+
+func (w *Writer) dial() error {
+	var err error
+
+	switch w.network {
+	case "tls":
+		config := w.tlsConfig
+		if config == nil {
+			config = &tls.Config{}
+		}
+
+		dialer := &net.Dialer{Timeout: 10 * time.Second}
+		w.conn, err = tls.DialWithDialer(dialer, "tcp", w.address, config)
+
+	case "unix":
+		// Local syslog daemons usually listen on a datagram socket, but
+		// some use a stream.
+		w.conn, err = net.Dial("unixgram", w.address)
+		w.stream = false
+		if err != nil {
+			w.conn, err = net.Dial("unix", w.address)
+			w.stream = true
+		}
+
+	default:
+		w.conn, err = net.DialTimeout(w.network, w.address, 10*time.Second)
+	}
+
+	return err
+}
+
+// Write sends a message with the given severity, message ID, structured
+// data and message. An empty structuredData is sent as "-".
+func (w *Writer) Write(severity Severity, ts time.Time, msgID string, structuredData string, msg string) error {
+	if structuredData == "" {
+		structuredData = "-"
+	}
+
+	line := fmt.Sprintf("<%d>1 %s %s %s %s %s %s %s",
+		int(w.Facility)*8+int(severity),
+		ts.Format("2006-01-02T15:04:05.000000Z07:00"),
+		headerField(w.Hostname, 255),
+		headerField(w.AppName, 48),
+		headerField(w.procID, 128),
+		headerField(msgID, 32),
+		structuredData,
+		msg,
+	)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		err := w.dial()
+		if err != nil {
+			return err
+		}
+	}
+
+	// Redialing a unix socket can change whether it is a stream.
+	if w.stream {
+		line = strconv.Itoa(len(line)) + " " + line
+	}
+
+	_ = w.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+
+	// A datagram socket is broken too if the syslog daemon restarted.
+	_, err := w.conn.Write([]byte(line))
+	if err != nil {
+		w.conn.Close()
+		w.conn = nil
+	}
+
+	return err
+}
+
+// Close closes the connection.
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.conn == nil {
+		return nil
+	}
+
+	err := w.conn.Close()
+	w.conn = nil
+
+	return err
+}
+
+// headerField returns s as a header field of at most max printable ASCII
+// characters, or "-" if empty.
+func headerField(s string, max int) string {
+	s = strings.Map(func(r rune) rune {
+		if r < 33 || r > 126 {
+			return -1
+		}
+
+		return r
+	}, s)
+
+	if len(s) > max {
+		s = s[:max]
+	}
+
+	if s == "" {
+		return "-"
+	}
+
+	return s
+}
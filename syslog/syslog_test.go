@@ -0,0 +1,78 @@
+package syslog
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestRedial checks that a unix datagram socket is redialed when the
+// syslog daemon restarts.
+func TestRedial(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log")
+
+	listen := func() *net.UnixConn {
+		conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		return conn
+	}
+
+	receive := func(conn *net.UnixConn) string {
+		buf := make([]byte, 2048)
+
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+		n, err := conn.Read(buf)
+		if err != nil {
+			t.Fatalf("%s", err)
+		}
+
+		return string(buf[:n])
+	}
+
+	server := listen()
+
+	w, err := Dial("unix://"+path, nil)
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+	defer w.Close()
+
+	err = w.Write(Info, time.Now(), "test", "", "before")
+	if err != nil {
+		t.Fatalf("%s", err)
+	}
+
+	if msg := receive(server); !strings.HasPrefix(msg, "<134>1 ") || !strings.HasSuffix(msg, " test - before") {
+		t.Errorf("got '%s'", msg)
+	}
+
+	// Restart.
+	server.Close()
+	os.Remove(path)
+
+	server = listen()
+	defer server.Close()
+
+	// The first write may fail, but redials for the next.
+	for n := 0; n < 2; n++ {
+		err = w.Write(Info, time.Now(), "test", "", "after")
+		if err == nil {
+			break
+		}
+	}
+
+	if err != nil {
+		t.Fatalf("not redialed: %s", err)
+	}
+
+	if msg := receive(server); !strings.HasSuffix(msg, " test - after") {
+		t.Errorf("got '%s'", msg)
+	}
+}